@@ -0,0 +1,47 @@
+package steve
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Clock provides the current time and timers to the runner, see WithClock. Providing a fake
+// clock allows tests of time based behavior, such as RetainAfterStop, to advance time instantly.
+type Clock interface {
+	// Now returns the current time
+	Now() time.Time
+	// After returns a channel which receives the current time once the duration has elapsed
+	After(time.Duration) <-chan time.Time
+}
+
+// realClock is the Clock used by default, which reads the system time
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// afterFunc calls f once the duration has elapsed according to the clock, and returns a function
+// which prevents the call. Like time.Timer.Stop, the returned function returns false if f has
+// already been called.
+func afterFunc(c Clock, d time.Duration, f func()) func() bool {
+	// state is 0 while waiting, 1 once f is called and 2 once stopped
+	var state int32
+	stopped := make(chan struct{})
+	after := c.After(d)
+	go func() {
+		select {
+		case <-after:
+			if atomic.CompareAndSwapInt32(&state, 0, 1) {
+				f()
+			}
+		case <-stopped:
+		}
+	}()
+	return func() bool {
+		if atomic.CompareAndSwapInt32(&state, 0, 2) {
+			close(stopped)
+			return true
+		}
+		return false
+	}
+}
@@ -0,0 +1,170 @@
+package steve_test
+
+import (
+	"context"
+	"io"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mailgun/holster/v4/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thrawn01/steve"
+)
+
+// fakeClock is a steve.Clock which only advances when Advance is called
+type fakeClock struct {
+	mutex   sync.Mutex
+	now     time.Time
+	waiters []fakeWaiter
+}
+
+type fakeWaiter struct {
+	at time.Time
+	ch chan time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- c.now
+		return ch
+	}
+	c.waiters = append(c.waiters, fakeWaiter{at: c.now.Add(d), ch: ch})
+	return ch
+}
+
+// Waiting returns the number of channels returned by After which have not yet fired
+func (c *fakeClock) Waiting() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return len(c.waiters)
+}
+
+// Advance moves the clock forward, firing any waiters which are due
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.now = c.now.Add(d)
+	waiting := c.waiters[:0]
+	for _, w := range c.waiters {
+		if w.at.After(c.now) {
+			waiting = append(waiting, w)
+			continue
+		}
+		w.ch <- c.now
+	}
+	c.waiters = waiting
+}
+
+func TestWithClock(t *testing.T) {
+	clock := newFakeClock()
+	runner := steve.NewJobRunner(20, steve.WithClock(clock), steve.WithSweepInterval(time.Minute))
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	defer func() { require.NoError(t, runner.Close(ctx)) }()
+
+	t.Run("ReaderTimeout", func(t *testing.T) {
+		id, err := runner.Run(ctx, &linesJob{count: 10_000})
+		require.NoError(t, err)
+		defer func() { require.NoError(t, runner.Stop(ctx, id)) }()
+
+		r, err := runner.NewReaderTimeout(id, time.Hour)
+		require.NoError(t, err)
+		_, err = r.Read(make([]byte, 10))
+		require.NoError(t, err)
+
+		// The timeout is only reached once the clock is advanced
+		clock.Advance(time.Minute)
+		count, err := runner.ReaderCount(id)
+		require.NoError(t, err)
+		assert.Equal(t, 1, count)
+
+		clock.Advance(time.Hour)
+		testutil.UntilPass(t, 10, time.Millisecond*100, func(t testutil.TestingT) {
+			count, err := runner.ReaderCount(id)
+			assert.NoError(t, err)
+			assert.Equal(t, 0, count)
+		})
+		_, err = io.ReadAll(r)
+		assert.ErrorIs(t, err, steve.ErrReaderTimeout)
+	})
+
+	t.Run("Duration", func(t *testing.T) {
+		job := newWriterJob()
+		id, err := runner.Run(ctx, job)
+		require.NoError(t, err)
+		job.Write("hello")
+		testutil.UntilPass(t, 10, time.Millisecond*100, func(t testutil.TestingT) {
+			n, err := runner.BufferLen(id)
+			assert.NoError(t, err)
+			assert.Equal(t, 6, n)
+		})
+
+		// The duration and throughput of a running job are measured with the clock
+		clock.Advance(time.Second * 3)
+		s, ok := runner.Status(id)
+		require.True(t, ok)
+		assert.True(t, s.Running)
+		assert.Equal(t, time.Second*3, s.Duration())
+		assert.Equal(t, float64(2), s.Throughput)
+
+		close(job.lines)
+		<-job.done
+		require.NoError(t, runner.Stop(ctx, id))
+	})
+
+	t.Run("MaxBackoff", func(t *testing.T) {
+		job := &flakyJob{failures: 100}
+		id, err := runner.RunWithOptions(ctx, job, steve.RunOptions{
+			Restart: steve.RestartPolicy{MaxAttempts: 40, Backoff: time.Hour, MaxBackoff: time.Hour * 4},
+		})
+		require.NoError(t, err)
+
+		// The backoff never exceeds MaxBackoff, nor overflows such that the job restarts without waiting
+		for attempt := int64(1); attempt < 40; attempt++ {
+			testutil.UntilPass(t, 100, time.Millisecond, func(t testutil.TestingT) {
+				assert.Equal(t, 1, clock.Waiting())
+			})
+			assert.Equal(t, attempt, atomic.LoadInt64(&job.starts))
+			clock.Advance(time.Hour * 4)
+		}
+		require.NoError(t, runner.Stop(ctx, id))
+	})
+
+	t.Run("RetainAfterStop", func(t *testing.T) {
+		id, err := runner.RunWithOptions(ctx, &linesJob{count: 1}, steve.RunOptions{
+			RetainAfterStop: time.Hour,
+		})
+		require.NoError(t, err)
+		require.NoError(t, runner.Stop(ctx, id))
+
+		testutil.UntilPass(t, 10, time.Millisecond*100, func(t testutil.TestingT) {
+			s, ok := runner.Status(id)
+			assert.True(t, ok)
+			assert.False(t, s.Running)
+			assert.Equal(t, clock.Now(), s.Stopped)
+		})
+
+		// Each advance fires the sweeper, which removes the job once the TTL has elapsed
+		testutil.UntilPass(t, 10, time.Millisecond*100, func(t testutil.TestingT) {
+			clock.Advance(time.Hour)
+			_, ok := runner.Status(id)
+			assert.False(t, ok)
+		})
+	})
+}
@@ -0,0 +1,41 @@
+package steve
+
+import (
+	"unicode/utf8"
+)
+
+// Decoder transcodes a chunk of job output to UTF-8, returning the decoded output and the number of
+// bytes of src which were consumed. Bytes which are not consumed, such as a multi-byte character split
+// across chunks, are provided again at the start of the next chunk. atEOF is true once the job has
+// stopped writing output, in which case the decoder must consume all of src.
+type Decoder func(src []byte, atEOF bool) ([]byte, int)
+
+// DecodeLatin1 is a Decoder which transcodes ISO-8859-1 (latin1) output to UTF-8
+func DecodeLatin1(src []byte, atEOF bool) ([]byte, int) {
+	out := make([]byte, 0, len(src))
+	for _, b := range src {
+		out = utf8.AppendRune(out, rune(b))
+	}
+	return out, len(src)
+}
+
+// DecodeUTF8 is a Decoder for output which is expected to be UTF-8, it replaces any invalid byte
+// sequences with utf8.RuneError such that the stored output is always valid UTF-8.
+func DecodeUTF8(src []byte, atEOF bool) ([]byte, int) {
+	out := make([]byte, 0, len(src))
+	var n int
+	for n < len(src) {
+		// Hold back a character which may be completed by the next chunk
+		if !atEOF && !utf8.FullRune(src[n:]) {
+			break
+		}
+		r, size := utf8.DecodeRune(src[n:])
+		if r == utf8.RuneError && size == 1 {
+			out = utf8.AppendRune(out, utf8.RuneError)
+		} else {
+			out = append(out, src[n:n+size]...)
+		}
+		n += size
+	}
+	return out, n
+}
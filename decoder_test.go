@@ -0,0 +1,72 @@
+package steve_test
+
+import (
+	"context"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/mailgun/holster/v4/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thrawn01/steve"
+)
+
+func TestDecodeUTF8(t *testing.T) {
+	// A multi-byte character split across chunks is held back until complete
+	out, n := steve.DecodeUTF8([]byte("price: \xe2\x82"), false)
+	assert.Equal(t, "price: ", string(out))
+	assert.Equal(t, 7, n)
+
+	out, n = steve.DecodeUTF8([]byte("\xe2\x82\xac5\n"), false)
+	assert.Equal(t, "€5\n", string(out))
+	assert.Equal(t, 5, n)
+
+	// Invalid bytes are replaced, as is an incomplete character once at EOF
+	out, n = steve.DecodeUTF8([]byte("bad \xff \xe2\x82"), true)
+	assert.Equal(t, "bad � ��", string(out))
+	assert.Equal(t, 8, n)
+}
+
+func TestRunDecoder(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		decoder  steve.Decoder
+		chunks   []string
+		expected string
+	}{
+		{
+			name:     "Latin1",
+			decoder:  steve.DecodeLatin1,
+			chunks:   []string{"caf\xe9 cr\xe8me ", "br\xfbl\xe9e\n"},
+			expected: "café crème brûlée\n",
+		},
+		{
+			name:     "UTF8",
+			decoder:  steve.DecodeUTF8,
+			chunks:   []string{"price: \xe2\x82", "\xac5 \xff\n", "\xe2\x82"},
+			expected: "price: €5 �\n��",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			runner := steve.NewJobRunner(20)
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+			defer cancel()
+
+			id, err := runner.RunWithOptions(ctx, &chunksJob{chunks: test.chunks}, steve.RunOptions{Decoder: test.decoder})
+			require.NoError(t, err)
+			require.NoError(t, runner.Stop(ctx, id))
+
+			testutil.UntilPass(t, 10, time.Millisecond*100, func(t testutil.TestingT) {
+				s, ok := runner.Status(id)
+				assert.True(t, ok)
+				assert.False(t, s.Running)
+			})
+
+			data, _, err := runner.Snapshot(id)
+			require.NoError(t, err)
+			assert.True(t, utf8.Valid(data))
+			assert.Equal(t, test.expected, string(data))
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package steve
+
+import (
+	"sync"
+)
+
+// EventBufferSize is the number of events buffered for each subscriber. Events sent to a
+// subscriber whose buffer is full are dropped, such that slow subscribers never block the runner.
+const EventBufferSize = 100
+
+type EventType int
+
+const (
+	// EventStarted is sent once Start has returned without error
+	EventStarted EventType = iota
+	// EventStopped is sent when a job is no longer running
+	EventStopped
+	// EventEvicted is sent when a job is evicted from the runner
+	EventEvicted
+	// EventOutput is sent when a job has written new output
+	EventOutput
+)
+
+func (e EventType) String() string {
+	switch e {
+	case EventStarted:
+		return "started"
+	case EventStopped:
+		return "stopped"
+	case EventEvicted:
+		return "evicted"
+	case EventOutput:
+		return "output"
+	}
+	return "unknown"
+}
+
+// Event is a lifecycle notification for a job
+type Event struct {
+	Type EventType `json:"type"`
+	ID   ID        `json:"id"`
+}
+
+// events fans out events to all subscribers
+type events struct {
+	subscribers map[chan Event]struct{}
+	mutex       sync.Mutex
+}
+
+func newEvents() *events {
+	return &events{
+		subscribers: make(map[chan Event]struct{}),
+	}
+}
+
+// Subscribe returns a channel which receives all events sent after subscribing, and a
+// function which unsubscribes and closes the channel.
+func (e *events) Subscribe() (<-chan Event, func()) {
+	ch := make(chan Event, EventBufferSize)
+
+	e.mutex.Lock()
+	e.subscribers[ch] = struct{}{}
+	e.mutex.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			e.mutex.Lock()
+			delete(e.subscribers, ch)
+			close(ch)
+			e.mutex.Unlock()
+		})
+	}
+}
+
+// Send the event to all subscribers, dropping the event for any
+// subscriber whose buffer is full.
+func (e *events) Send(t EventType, id ID) {
+	defer e.mutex.Unlock()
+	e.mutex.Lock()
+
+	for ch := range e.subscribers {
+		select {
+		case ch <- Event{Type: t, ID: id}:
+		default:
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package steve_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mailgun/holster/v4/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thrawn01/steve"
+)
+
+func TestEvents(t *testing.T) {
+	runner := steve.NewJobRunner(1)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	events, unsubscribe := runner.Events()
+
+	id, err := runner.Run(ctx, &linesJob{count: 3})
+	require.NoError(t, err)
+	require.NoError(t, runner.Stop(ctx, id))
+
+	// Collect events until the job stops
+	var got []steve.Event
+	for e := range events {
+		got = append(got, e)
+		if e.Type == steve.EventStopped {
+			break
+		}
+	}
+	require.NotEmpty(t, got)
+	assert.Equal(t, steve.Event{Type: steve.EventStarted, ID: id}, got[0])
+	assert.Equal(t, steve.Event{Type: steve.EventOutput, ID: id}, got[1])
+	assert.Equal(t, steve.Event{Type: steve.EventStopped, ID: id}, got[len(got)-1])
+
+	// Running a second job evicts the first from a runner with a capacity of 1
+	next, err := runner.Run(ctx, &linesJob{count: 1})
+	require.NoError(t, err)
+	assert.Equal(t, steve.Event{Type: steve.EventEvicted, ID: id}, <-events)
+	assert.Equal(t, steve.Event{Type: steve.EventStarted, ID: next}, <-events)
+
+	// Unsubscribing closes the channel
+	unsubscribe()
+	unsubscribe()
+	for range events {
+	}
+}
+
+func TestEventsFailedStart(t *testing.T) {
+	runner := steve.NewJobRunner(20)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	defer func() { require.NoError(t, runner.Close(ctx)) }()
+
+	events, unsubscribe := runner.Events()
+	defer unsubscribe()
+
+	// No events are sent for a job which fails to start
+	_, err := runner.Run(ctx, &failJob{})
+	require.ErrorIs(t, err, errFailedStart)
+
+	id, err := runner.Run(ctx, &linesJob{count: 1})
+	require.NoError(t, err)
+	assert.Equal(t, steve.Event{Type: steve.EventStarted, ID: id}, <-events)
+}
+
+func TestEventsSlowSubscriber(t *testing.T) {
+	runner := steve.NewJobRunner(20)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	// Subscribe but never read from the channel
+	events, unsubscribe := runner.Events()
+	defer unsubscribe()
+
+	job := newWriterJob()
+	id, err := runner.Run(ctx, job)
+	require.NoError(t, err)
+
+	// Generate more events than the subscriber can buffer
+	for i := 0; i < steve.EventBufferSize*2; i++ {
+		job.Write("line")
+	}
+	require.NoError(t, runner.Stop(ctx, id))
+
+	testutil.UntilPass(t, 10, time.Millisecond*100, func(t testutil.TestingT) {
+		s, _ := runner.Status(id)
+		assert.False(t, s.Running)
+	})
+	assert.Len(t, events, steve.EventBufferSize)
+}
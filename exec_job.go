@@ -0,0 +1,219 @@
+package steve
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// DefaultGrace is the default time ExecJob.Stop waits for a process to
+// exit after being asked to terminate before it is killed.
+const DefaultGrace = 10 * time.Second
+
+// ExecJob is a Job which runs an OS process, writing the combined stdout and
+// stderr of the process to the job output unless routed elsewhere by Stdout or
+// Stderr. The job completes when the process exits.
+type ExecJob struct {
+	// Name is the name or path of the program to run
+	Name string
+	// Args are the arguments passed to the program
+	Args []string
+	// Env are environment variables in the form "key=value" which are added to the
+	// environment of the current process. Variables in Env take precedence over
+	// variables of the same name in the current environment.
+	Env []string
+	// Dir is the working directory of the process. If empty, the process runs
+	// in the working directory of the current process.
+	Dir string
+	// Grace is how long Stop waits for the process to exit after sending SIGTERM
+	// before sending SIGKILL. Defaults to DefaultGrace if zero.
+	Grace time.Duration
+	// Stdout if not nil, receives the stdout of the process instead of the job output,
+	// for instance to write stdout to a file while stderr is captured by the runner.
+	Stdout io.Writer
+	// Stderr if not nil, receives the stderr of the process instead of the job output
+	Stderr io.Writer
+	// Interactive if true, the stdin of the process reads the input written via Runner.Input.
+	// Otherwise the stdin of the process is the null device and input is never read.
+	Interactive bool
+
+	cmd      *exec.Cmd
+	done     chan struct{}
+	exitCode *int
+	// stopping is true once Stop signals the process, such that the process exiting
+	// because of the signal is reported as a clean stop rather than an error.
+	stopping bool
+	mutex    sync.Mutex
+}
+
+// NewExecJob returns a Job which runs the named program with the provided arguments
+func NewExecJob(name string, args ...string) *ExecJob {
+	return &ExecJob{
+		Name: name,
+		Args: args,
+	}
+}
+
+func (e *ExecJob) Start(ctx context.Context, writer io.Writer) error {
+	return e.start(ctx, writer, nil)
+}
+
+// StartWithInput is identical to Start but if Interactive is true, copies the input to the
+// stdin of the process, closing stdin once the input returns io.EOF.
+func (e *ExecJob) StartWithInput(ctx context.Context, writer io.Writer, input io.Reader) error {
+	if !e.Interactive {
+		input = nil
+	}
+	return e.start(ctx, writer, input)
+}
+
+// AcceptsInput returns true if the job is Interactive, as otherwise the process never reads input
+func (e *ExecJob) AcceptsInput() bool {
+	return e.Interactive
+}
+
+func (e *ExecJob) start(ctx context.Context, writer io.Writer, input io.Reader) error {
+	cmd := exec.CommandContext(ctx, e.Name, e.Args...)
+	cmd.Stdout = writer
+	if e.Stdout != nil {
+		cmd.Stdout = e.Stdout
+	}
+	cmd.Stderr = writer
+	if e.Stderr != nil {
+		cmd.Stderr = e.Stderr
+	}
+	cmd.Dir = e.Dir
+	if len(e.Env) != 0 {
+		// When duplicate keys exist, exec.Cmd uses the last value
+		cmd.Env = append(os.Environ(), e.Env...)
+	}
+	setProcessGroup(cmd)
+	cmd.Cancel = func() error {
+		return killProcessGroup(cmd)
+	}
+
+	var stdin io.WriteCloser
+	if input != nil {
+		var err error
+		if stdin, err = cmd.StdinPipe(); err != nil {
+			return err
+		}
+	}
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	if stdin != nil {
+		go func() {
+			_, _ = io.Copy(stdin, input)
+			_ = stdin.Close()
+		}()
+	}
+
+	done := make(chan struct{})
+	e.mutex.Lock()
+	e.cmd = cmd
+	e.done = done
+	e.exitCode = nil
+	e.stopping = false
+	e.mutex.Unlock()
+
+	go func() {
+		err := cmd.Wait()
+		code := cmd.ProcessState.ExitCode()
+		e.mutex.Lock()
+		e.exitCode = &code
+		var exitErr *exec.ExitError
+		if e.stopping && errors.As(err, &exitErr) {
+			err = nil
+		}
+		e.mutex.Unlock()
+
+		// Close the writer to signal the job has completed, or failed if the process
+		// exited with a non-zero exit code for any reason other than being stopped.
+		if w, ok := writer.(*io.PipeWriter); ok {
+			_ = w.CloseWithError(err)
+		}
+		close(done)
+	}()
+	return nil
+}
+
+// Stop sends SIGTERM to the process group of the running process and waits for the
+// process to exit. If the process has not exited after the grace period, the process
+// group is sent SIGKILL. Returns an error if the context is cancelled before the
+// process exits. A process which exits once stopped is not reported as failed.
+func (e *ExecJob) Stop(ctx context.Context) error {
+	e.mutex.Lock()
+	cmd, done := e.cmd, e.done
+	e.mutex.Unlock()
+
+	if cmd == nil {
+		return nil
+	}
+
+	select {
+	case <-done:
+		return nil
+	default:
+	}
+
+	e.mutex.Lock()
+	e.stopping = true
+	e.mutex.Unlock()
+	if err := terminateProcessGroup(cmd); err != nil {
+		return err
+	}
+
+	grace := e.Grace
+	if grace == 0 {
+		grace = DefaultGrace
+	}
+
+	select {
+	case <-done:
+		return nil
+	case <-time.After(grace):
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	if err := killProcessGroup(cmd); err != nil {
+		return err
+	}
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Kill sends SIGKILL to the process group of the running process
+func (e *ExecJob) Kill() error {
+	e.mutex.Lock()
+	cmd := e.cmd
+	e.mutex.Unlock()
+
+	if cmd == nil {
+		return nil
+	}
+	return killProcessGroup(cmd)
+}
+
+// ExitCode returns the exit code of the process and true once the process has
+// exited. The exit code is -1 if the process was terminated by a signal.
+func (e *ExecJob) ExitCode() (int, bool) {
+	defer e.mutex.Unlock()
+	e.mutex.Lock()
+
+	if e.exitCode == nil {
+		return 0, false
+	}
+	return *e.exitCode, true
+}
@@ -0,0 +1,268 @@
+package steve_test
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mailgun/holster/v4/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thrawn01/steve"
+)
+
+func TestExecJob(t *testing.T) {
+	runner := steve.NewJobRunner(20)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	id, err := runner.Run(ctx, steve.NewExecJob("echo", "hello"))
+	require.NoError(t, err)
+
+	// The job should stop once the process exits
+	testutil.UntilPass(t, 20, time.Millisecond*100, func(t testutil.TestingT) {
+		s, ok := runner.Status(id)
+		require.True(t, ok)
+		assert.False(t, s.Running)
+	})
+
+	r, err := runner.NewReader(id)
+	require.NoError(t, err)
+	out, err := io.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, "hello\n", string(out))
+}
+
+func TestExecJobEnvAndDir(t *testing.T) {
+	runner := steve.NewJobRunner(20)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	t.Setenv("STEVE_PARENT", "parent")
+	t.Setenv("STEVE_OVERRIDE", "parent")
+	dir := t.TempDir()
+
+	job := steve.NewExecJob("sh", "-c", "echo $STEVE_PARENT $STEVE_OVERRIDE $STEVE_CHILD; pwd")
+	job.Env = []string{"STEVE_OVERRIDE=child", "STEVE_CHILD=child"}
+	job.Dir = dir
+
+	id, err := runner.Run(ctx, job)
+	require.NoError(t, err)
+
+	testutil.UntilPass(t, 20, time.Millisecond*100, func(t testutil.TestingT) {
+		s, ok := runner.Status(id)
+		require.True(t, ok)
+		assert.False(t, s.Running)
+	})
+
+	r, err := runner.NewReader(id)
+	require.NoError(t, err)
+	out, err := io.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, "parent child child\n"+dir+"\n", string(out))
+}
+
+func TestExecJobSeparateStreams(t *testing.T) {
+	runner := steve.NewJobRunner(20)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	f, err := os.Create(filepath.Join(t.TempDir(), "stdout"))
+	require.NoError(t, err)
+	defer f.Close()
+
+	job := steve.NewExecJob("sh", "-c", "echo to stdout; echo to stderr >&2")
+	job.Stdout = f
+
+	id, err := runner.Run(ctx, job)
+	require.NoError(t, err)
+
+	testutil.UntilPass(t, 20, time.Millisecond*100, func(t testutil.TestingT) {
+		s, ok := runner.Status(id)
+		require.True(t, ok)
+		assert.False(t, s.Running)
+	})
+
+	// Only stderr is captured by the runner
+	r, err := runner.NewReader(id)
+	require.NoError(t, err)
+	out, err := io.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, "to stderr\n", string(out))
+
+	stdout, err := os.ReadFile(f.Name())
+	require.NoError(t, err)
+	assert.Equal(t, "to stdout\n", string(stdout))
+}
+
+func TestExecJobInput(t *testing.T) {
+	runner := steve.NewJobRunner(20)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	job := steve.NewExecJob("cat")
+	job.Interactive = true
+	id, err := runner.Run(ctx, job)
+	require.NoError(t, err)
+
+	r, err := runner.NewReader(id)
+	require.NoError(t, err)
+	w, err := runner.Input(id)
+	require.NoError(t, err)
+	_, err = io.WriteString(w, "hello from stdin\n")
+	require.NoError(t, err)
+
+	// The process exits once stdin is closed
+	require.NoError(t, w.Close())
+	out, err := io.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, "hello from stdin\n", string(out))
+
+	testutil.UntilPass(t, 20, time.Millisecond*100, func(t testutil.TestingT) {
+		s, ok := runner.Status(id)
+		require.True(t, ok)
+		assert.False(t, s.Running)
+		require.NotNil(t, s.ExitCode)
+		assert.Equal(t, 0, *s.ExitCode)
+	})
+
+	// A process which is not interactive reads nothing from stdin
+	id, err = runner.Run(ctx, steve.NewExecJob("cat"))
+	require.NoError(t, err)
+	_, err = runner.Input(id)
+	assert.ErrorIs(t, err, steve.ErrNoInput)
+	testutil.UntilPass(t, 20, time.Millisecond*100, func(t testutil.TestingT) {
+		s, ok := runner.Status(id)
+		require.True(t, ok)
+		assert.False(t, s.Running)
+	})
+}
+
+func TestExecJobExitCode(t *testing.T) {
+	runner := steve.NewJobRunner(20)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	for _, tt := range []struct {
+		name string
+		cmd  string
+		code int
+	}{
+		{name: "Success", cmd: "exit 0", code: 0},
+		{name: "Failure", cmd: "exit 3", code: 3},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			id, err := runner.Run(ctx, steve.NewExecJob("sh", "-c", tt.cmd))
+			require.NoError(t, err)
+
+			testutil.UntilPass(t, 20, time.Millisecond*100, func(t testutil.TestingT) {
+				s, ok := runner.Status(id)
+				require.True(t, ok)
+				assert.False(t, s.Running)
+				if assert.NotNil(t, s.ExitCode) {
+					assert.Equal(t, tt.code, *s.ExitCode)
+				}
+			})
+		})
+	}
+
+	// No exit code while the process is running
+	id, err := runner.Run(ctx, steve.NewExecJob("sleep", "60"))
+	require.NoError(t, err)
+	s, _ := runner.Status(id)
+	assert.Nil(t, s.ExitCode)
+	require.NoError(t, runner.Stop(ctx, id))
+}
+
+func TestExecJobStop(t *testing.T) {
+	runner := steve.NewJobRunner(20)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	id, err := runner.Run(ctx, steve.NewExecJob("sleep", "60"))
+	require.NoError(t, err)
+
+	s, ok := runner.Status(id)
+	require.True(t, ok)
+	assert.True(t, s.Running)
+
+	require.NoError(t, runner.Stop(ctx, id))
+	testutil.UntilPass(t, 20, time.Millisecond*100, func(t testutil.TestingT) {
+		s, ok := runner.Status(id)
+		require.True(t, ok)
+		assert.False(t, s.Running)
+		assert.Empty(t, s.Err)
+	})
+
+	// A process terminated by anything other than Stop is reported as failed
+	id, err = runner.Run(ctx, steve.NewExecJob("sh", "-c", "kill -TERM $$"))
+	require.NoError(t, err)
+	testutil.UntilPass(t, 20, time.Millisecond*100, func(t testutil.TestingT) {
+		s, ok := runner.Status(id)
+		require.True(t, ok)
+		assert.False(t, s.Running)
+		assert.Equal(t, "signal: terminated", s.Err)
+	})
+}
+
+func TestExecJobIgnoresSIGTERM(t *testing.T) {
+	runner := steve.NewJobRunner(20)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	const grace = time.Millisecond * 500
+	const script = "trap '' TERM; echo ready; sleep 60"
+
+	// Wait for the script to install the trap before stopping
+	waitReady := func(t *testing.T, id steve.ID) {
+		r, err := runner.NewReader(id)
+		require.NoError(t, err)
+		line, err := bufio.NewReader(r).ReadString('\n')
+		require.NoError(t, err)
+		assert.Equal(t, "ready\n", line)
+		require.NoError(t, r.Close())
+	}
+
+	t.Run("StopGraceful", func(t *testing.T) {
+		id, err := runner.Run(ctx, steve.NewExecJob("sh", "-c", script))
+		require.NoError(t, err)
+		waitReady(t, id)
+
+		start := time.Now()
+		require.NoError(t, runner.StopGraceful(ctx, id, grace))
+		assert.GreaterOrEqual(t, time.Since(start), grace)
+
+		testutil.UntilPass(t, 20, time.Millisecond*100, func(t testutil.TestingT) {
+			s, ok := runner.Status(id)
+			require.True(t, ok)
+			assert.False(t, s.Running)
+			assert.Empty(t, s.Err)
+		})
+	})
+
+	t.Run("Stop", func(t *testing.T) {
+		job := steve.NewExecJob("sh", "-c", script)
+		job.Grace = grace
+		id, err := runner.Run(ctx, job)
+		require.NoError(t, err)
+		waitReady(t, id)
+
+		start := time.Now()
+		require.NoError(t, runner.Stop(ctx, id))
+		assert.GreaterOrEqual(t, time.Since(start), grace)
+
+		testutil.UntilPass(t, 20, time.Millisecond*100, func(t testutil.TestingT) {
+			s, ok := runner.Status(id)
+			require.True(t, ok)
+			assert.False(t, s.Running)
+			assert.Empty(t, s.Err)
+			if assert.NotNil(t, s.ExitCode) {
+				assert.Equal(t, -1, *s.ExitCode)
+			}
+		})
+	})
+}
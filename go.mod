@@ -6,10 +6,12 @@ require (
 	github.com/google/uuid v1.3.0
 	github.com/mailgun/holster/v4 v4.14.3
 	github.com/stretchr/testify v1.8.4
+	go.uber.org/goleak v1.2.1
 )
 
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/kr/text v0.2.0 // indirect
 	github.com/pkg/errors v0.9.1 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	github.com/sirupsen/logrus v1.9.2 // indirect
@@ -0,0 +1,246 @@
+package steve
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/mailgun/holster/v4/syncutil"
+)
+
+// DefaultGroupRetention is the number of bytes of output retained by a group, unless the job which
+// creates the group retains fewer bytes, see RunOptions.Retention.
+const DefaultGroupRetention = 1024 * 1024
+
+// group is the output shared by the jobs run with RunOptions.Group. Each complete line written by
+// a job in the group is stored prefixed with the ID of the job, such that the output of the jobs
+// interleaves by line.
+type group struct {
+	mutex sync.Mutex
+	br    syncutil.Broadcaster
+	store OutputStore
+	// running is the number of jobs in the group which are running
+	running int
+	// counted is the number of bytes of the store counted toward runner.usage
+	counted int
+	// members are the IDs of the jobs in the group which are held by the runner, the group
+	// is removed from the runner once it has no members. Guarded by runner.groupMutex.
+	members map[ID]struct{}
+}
+
+// join adds the job to the group with the provided name, creating the group if needed. The job
+// is counted as running in the group until the monitor go routine of the job calls groupStop.
+// Returns false if a job with the same ID, such as the job being restarted, is already a member.
+func (r *runner) join(j *jobIO, name string) bool {
+	defer r.groupMutex.Unlock()
+	r.groupMutex.Lock()
+
+	if r.groups == nil {
+		r.groups = make(map[string]*group)
+	}
+	g, ok := r.groups[name]
+	if !ok {
+		// The group outlives any one job, so the output of the group is always bounded
+		size := DefaultGroupRetention
+		if n := j.opts.Retention.bytes; n != 0 && n < size {
+			size = n
+		}
+		g = &group{
+			br:      syncutil.NewBroadcaster(),
+			store:   NewRingBufferStore(size),
+			members: make(map[ID]struct{}),
+		}
+		r.groups[name] = g
+	}
+	_, member := g.members[j.id]
+	g.members[j.id] = struct{}{}
+	g.mutex.Lock()
+	g.running++
+	g.mutex.Unlock()
+	j.group = g
+	return !member
+}
+
+// groupBytes returns the number of bytes of output retained by all groups
+func (r *runner) groupBytes() int {
+	defer r.groupMutex.Unlock()
+	r.groupMutex.Lock()
+
+	var total int
+	for _, g := range r.groups {
+		g.mutex.Lock()
+		total += g.store.Len()
+		g.mutex.Unlock()
+	}
+	return total
+}
+
+// truncateGroups discards up to n of the oldest bytes of output retained by a group in order to stay
+// within the memory budget, returning false if no group retains any output.
+func (r *runner) truncateGroups(n int) bool {
+	defer r.groupMutex.Unlock()
+	r.groupMutex.Lock()
+
+	for _, g := range r.groups {
+		g.mutex.Lock()
+		retained := g.store.Len()
+		if retained != 0 {
+			if n > retained {
+				n = retained
+			}
+			g.store.(Truncater).Truncate(n)
+			r.account(&g.counted, g.store.Len())
+		}
+		g.mutex.Unlock()
+		if retained != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// leave removes the job from its group once the job is no longer held by the runner
+func (r *runner) leave(j *jobIO) {
+	defer r.groupMutex.Unlock()
+	r.groupMutex.Lock()
+
+	g, ok := r.groups[j.opts.Group]
+	if !ok || g != j.group {
+		return
+	}
+	delete(g.members, j.id)
+	if len(g.members) == 0 {
+		delete(r.groups, j.opts.Group)
+		g.mutex.Lock()
+		r.account(&g.counted, 0)
+		g.mutex.Unlock()
+	}
+}
+
+// groupWrite stores the complete lines of the provided output in the group of the job. Only
+// accessed by the monitor go routine of the job.
+func (r *runner) groupWrite(j *jobIO, line []byte) {
+	lines := j.groupLines.Split(line)
+	if len(lines) == 0 {
+		return
+	}
+	if r.budget != 0 {
+		var n int
+		for _, l := range lines {
+			n += len(j.id) + len(l) + 3
+		}
+		r.makeRoom(n)
+	}
+	j.group.mutex.Lock()
+	for _, l := range lines {
+		_, _ = fmt.Fprintf(j.group.store, "[%s] %s", j.id, l)
+	}
+	r.account(&j.group.counted, j.group.store.Len())
+	j.group.br.Broadcast()
+	j.group.mutex.Unlock()
+}
+
+// groupStop stores any partial line of the stopped job in the group, then wakes the group readers
+// such that they return io.EOF once the last job in the group has stopped.
+func (r *runner) groupStop(j *jobIO) {
+	j.group.mutex.Lock()
+	if partial := j.groupLines.Flush(); len(partial) != 0 {
+		_, _ = fmt.Fprintf(j.group.store, "[%s] %s\n", j.id, partial)
+		r.account(&j.group.counted, j.group.store.Len())
+	}
+	j.group.running--
+	j.group.br.Broadcast()
+	j.group.mutex.Unlock()
+}
+
+func (r *runner) RunInGroup(ctx context.Context, name string, job Job) (ID, error) {
+	return r.RunWithOptions(ctx, job, RunOptions{Group: name})
+}
+
+func (r *runner) NewGroupReader(name string) (io.ReadCloser, error) {
+	defer r.mutex.Unlock()
+	r.mutex.Lock()
+
+	select {
+	case <-r.closed:
+		return nil, ErrRunnerClosed
+	default:
+	}
+
+	r.groupMutex.Lock()
+	g, ok := r.groups[name]
+	if !ok {
+		r.groupMutex.Unlock()
+		return nil, ErrGroupNotFound
+	}
+	members := make([]ID, 0, len(g.members))
+	for id := range g.members {
+		members = append(members, id)
+	}
+	r.groupMutex.Unlock()
+
+	// Register with the broadcaster before reading from the store, such that
+	// we don't miss a broadcast sent between our first read and our first wait.
+	key := uuid.New().String()
+	g.mutex.Lock()
+	wait := g.br.WaitChan(key)
+	g.mutex.Unlock()
+
+	reader, writer := io.Pipe()
+	closed := make(chan struct{})
+	p := &pipeReader{PipeReader: reader, writer: writer, closed: closed}
+
+	// The reader is attached to every job in the group, such that the reader is counted
+	// by ReaderCount and is waited for by Close like any other reader of the jobs.
+	var detach []func()
+	for _, id := range members {
+		obj, ok := r.jobs.Peek(id)
+		if !ok {
+			continue
+		}
+		j := obj.(*jobIO)
+		j.mutex.Lock()
+		detach = append(detach, j.attach(key))
+		j.handle(key, p.disconnect)
+		j.mutex.Unlock()
+	}
+
+	r.wg.Go(func() {
+		defer func() {
+			g.br.Remove(key)
+			for _, d := range detach {
+				d()
+			}
+		}()
+
+		var idx int
+		for {
+			g.mutex.Lock()
+			data, next := g.store.ReadOffset(idx)
+			running := g.running != 0
+			g.mutex.Unlock()
+
+			if len(data) != 0 {
+				if _, err := writer.Write(data); err != nil {
+					return
+				}
+			}
+			idx = next
+
+			// Once no job in the group is running, no more bytes will be written
+			if !running {
+				writer.Close()
+				return
+			}
+
+			select {
+			case <-wait:
+			case <-closed:
+				return
+			}
+		}
+	})
+	return p, nil
+}
@@ -0,0 +1,170 @@
+package steve_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/mailgun/holster/v4/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thrawn01/steve"
+)
+
+func TestRunInGroup(t *testing.T) {
+	runner := steve.NewJobRunner(20)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	_, err := runner.NewGroupReader("service")
+	assert.ErrorIs(t, err, steve.ErrGroupNotFound)
+
+	web, worker := newWriterJob(), newWriterJob()
+	webID, err := runner.RunInGroup(ctx, "service", web)
+	require.NoError(t, err)
+	workerID, err := runner.RunInGroup(ctx, "service", worker)
+	require.NoError(t, err)
+
+	r, err := runner.NewGroupReader("service")
+	require.NoError(t, err)
+
+	// Write each line once the previous line is stored, such that the order is known
+	var expected string
+	for i := 0; i < 2; i++ {
+		for _, w := range []struct {
+			id  steve.ID
+			job *writerJob
+		}{{webID, web}, {workerID, worker}} {
+			before, err := runner.BufferLen(w.id)
+			require.NoError(t, err)
+			line := fmt.Sprintf("line %d", i)
+			w.job.Write(line)
+			testutil.UntilPass(t, 10, time.Millisecond*100, func(t testutil.TestingT) {
+				n, err := runner.BufferLen(w.id)
+				assert.NoError(t, err)
+				assert.Equal(t, before+len(line)+1, n)
+			})
+			expected += fmt.Sprintf("[%s] %s\n", w.id, line)
+		}
+	}
+
+	// The output of each job is unchanged by the group
+	data, _, err := runner.Snapshot(webID)
+	require.NoError(t, err)
+	assert.Equal(t, "line 0\nline 1\n", string(data))
+
+	// The group reader returns io.EOF once every job in the group has stopped
+	for _, job := range []*writerJob{web, worker} {
+		close(job.lines)
+		<-job.done
+	}
+	require.NoError(t, runner.Stop(ctx, webID))
+	require.NoError(t, runner.Stop(ctx, workerID))
+	out, err := io.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, expected, string(out))
+
+	// The group is retained until every job in the group is removed
+	require.NoError(t, runner.Remove(webID))
+	r, err = runner.NewGroupReader("service")
+	require.NoError(t, err)
+	out, err = io.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, expected, string(out))
+
+	require.NoError(t, runner.Remove(workerID))
+	_, err = runner.NewGroupReader("service")
+	assert.ErrorIs(t, err, steve.ErrGroupNotFound)
+}
+
+func TestGroupReaderAttached(t *testing.T) {
+	runner := steve.NewJobRunner(20)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	job := newWriterJob()
+	id, err := runner.RunInGroup(ctx, "service", job)
+	require.NoError(t, err)
+	job.Write("line")
+	testutil.UntilPass(t, 10, time.Millisecond*100, func(t testutil.TestingT) {
+		n, err := runner.BufferLen(id)
+		assert.NoError(t, err)
+		assert.Equal(t, 5, n)
+	})
+
+	r, err := runner.NewGroupReader("service")
+	require.NoError(t, err)
+	count, err := runner.ReaderCount(id)
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	// Close waits for the group reader like any other reader
+	close(job.lines)
+	<-job.done
+	short, shortCancel := context.WithTimeout(ctx, time.Millisecond*50)
+	defer shortCancel()
+	assert.ErrorIs(t, runner.Close(short), context.DeadlineExceeded)
+
+	out, err := io.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, fmt.Sprintf("[%s] line\n", id), string(out))
+	require.NoError(t, runner.Close(ctx))
+	testutil.UntilPass(t, 10, time.Millisecond*100, func(t testutil.TestingT) {
+		count, err := runner.ReaderCount(id)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, count)
+	})
+
+	_, err = runner.NewGroupReader("service")
+	assert.ErrorIs(t, err, steve.ErrRunnerClosed)
+}
+
+func TestGroupRetention(t *testing.T) {
+	runner := steve.NewJobRunner(20)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	defer func() { require.NoError(t, runner.Close(ctx)) }()
+
+	// The group retains no more than the job which created the group
+	id, err := runner.RunWithOptions(ctx, &linesJob{count: 1_000}, steve.RunOptions{
+		Group:     "service",
+		Retention: steve.RetainBytes(100),
+	})
+	require.NoError(t, err)
+	require.NoError(t, runner.Stop(ctx, id))
+	testutil.UntilPass(t, 10, time.Millisecond*100, func(t testutil.TestingT) {
+		s, ok := runner.Status(id)
+		assert.True(t, ok)
+		assert.False(t, s.Running)
+	})
+
+	r, err := runner.NewGroupReader("service")
+	require.NoError(t, err)
+	out, err := io.ReadAll(r)
+	require.NoError(t, err)
+	assert.Len(t, out, 100)
+
+	// The output of the group counts toward the bytes buffered by the runner
+	assert.Equal(t, 200, runner.TotalBufferedBytes())
+}
+
+func TestGroupMemBudget(t *testing.T) {
+	runner := steve.NewJobRunnerMemBudget(1_000)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	defer func() { require.NoError(t, runner.Close(ctx)) }()
+
+	// The output of the job and the group together stay within the budget
+	id, err := runner.RunInGroup(ctx, "service", &linesJob{count: 1_000})
+	require.NoError(t, err)
+	require.NoError(t, runner.Stop(ctx, id))
+	testutil.UntilPass(t, 10, time.Millisecond*100, func(t testutil.TestingT) {
+		s, ok := runner.Status(id)
+		assert.True(t, ok)
+		assert.False(t, s.Running)
+	})
+	assert.LessOrEqual(t, runner.TotalBufferedBytes(), 1_000)
+	assert.NotZero(t, runner.TotalBufferedBytes())
+}
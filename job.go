@@ -2,27 +2,330 @@ package steve
 
 import (
 	"context"
+	"fmt"
 	"io"
+	"regexp"
 	"time"
 )
 
 type Status struct {
-	ID      ID        `json:"id"`
-	Running bool      `json:"running"`
-	Started time.Time `json:"started"`
-	Stopped time.Time `json:"stopped"`
+	ID       ID        `json:"id"`
+	Running  bool      `json:"running"`
+	Started  time.Time `json:"started"`
+	Stopped  time.Time `json:"stopped"`
+	Attempts int       `json:"attempts"`
+	Paused   bool      `json:"paused"`
+	Binary   bool      `json:"binary"`
+
+	// ExitCode is the exit code of a job which implements ExitCoder, nil while the job is running
+	ExitCode *int `json:"exitCode,omitempty"`
+
+	// BytesWritten is the total number of bytes of output written by the job, including output which
+	// was not retained, such as output discarded by RunOptions.Sample, the overflow policy or written while paused
+	BytesWritten int `json:"bytesWritten"`
+
+	// Throughput is the number of bytes per second written by the job while running
+	Throughput float64 `json:"throughput"`
+
+	// Retained is the number of bytes of output currently retained for the job
+	Retained int `json:"retained"`
+
+	// FirstOutput is the time the job first wrote output, FirstOutput.Sub(Started) is the startup
+	// latency of the job. The zero value means the job has not written any output.
+	FirstOutput time.Time `json:"firstOutput"`
+
+	// Pending is true while the job is waiting to be started, see RunOptions.Barrier, Runner.RunAfter
+	// and WithMaxConcurrent. A pending job is not Running.
+	Pending bool `json:"pending"`
+
+	// Canceled is true if the job was stopped while pending, in which case the job was never started
+	Canceled bool `json:"canceled"`
+
+	// Dropped is the number of bytes of output discarded by the overflow policy, see WithOutputBuffer
+	Dropped int `json:"dropped"`
+
+	// Err is the error the job failed with once it has stopped and will not be restarted, such as the
+	// error provided to CloseWithError, or ErrJobPanicked if the job panicked. Empty if the job didn't fail.
+	Err string `json:"err,omitempty"`
+
+	// now is the time the status was reported according to the clock of the runner, see WithClock
+	now time.Time
 }
 
+// Duration returns how long the job ran if the job has stopped, or how long
+// the job had been running when the status was reported if the job is still running.
+func (s Status) Duration() time.Duration {
+	if !s.Stopped.IsZero() {
+		return s.Stopped.Sub(s.Started)
+	}
+	if s.now.IsZero() {
+		return time.Since(s.Started)
+	}
+	return s.now.Sub(s.Started)
+}
+
+// Job is a job run by the Runner. The writer provided to Start is an *io.PipeWriter; a job which
+// finishes on its own should call Close() on the writer to indicate the job has completed, or
+// CloseWithError() to indicate the job has failed.
+//
+// The context provided to Start is a per-job context which carries the values of the context
+// provided to Run, but is only cancelled when the job is stopped via Stop or Close, or the
+// job has finished. The context provided to Run only governs the startup of the job.
 type Job interface {
-	// Start the job, returns an error if the job failed to start or context was canceled
+	// Start the job, returns an error if the job failed to start or context was canceled. Start should
+	// return as soon as the job has started, leaving the job running in the background, for instance in
+	// a goroutine, until it finishes or Stop is called. Run blocks until Start returns, if Start has not
+	// returned by the time the context provided to Run is cancelled, the job context is cancelled and Run
+	// returns the context error; a Start which blocks for the lifetime of the job never runs successfully.
 	Start(context.Context, io.Writer) error
 
 	// Stop the job, returns an error if the context was canceled before the job was stopped
 	Stop(context.Context) error
 }
 
+// ExitCoder is an optional interface implemented by jobs which have an exit code once finished,
+// such as ExecJob. The exit code is reported via Status.ExitCode once the job has stopped.
+type ExitCoder interface {
+	// ExitCode returns the exit code of the job and true if the job has exited
+	ExitCode() (int, bool)
+}
+
+// Killer is an optional interface implemented by jobs which can be forcibly terminated, such as
+// ExecJob. Runner.StopGraceful kills the job if it has not stopped within the grace period.
+type Killer interface {
+	// Kill forcibly terminates the job
+	Kill() error
+}
+
+// InputStarter is an optional interface implemented by jobs which read input, such as ExecJob. The
+// runner calls StartWithInput instead of Start, the input returns the bytes written to the writer
+// returned by Runner.Input, and returns io.EOF once that writer is closed.
+type InputStarter interface {
+	Job
+	// StartWithInput is identical to Start but also provides the input of the job
+	StartWithInput(ctx context.Context, writer io.Writer, input io.Reader) error
+}
+
+// InputAccepter is an optional interface implemented by an InputStarter which only reads input when
+// configured to, such as ExecJob with Interactive. If AcceptsInput returns false the job is started
+// with Start, and Runner.Input returns ErrNoInput.
+type InputAccepter interface {
+	AcceptsInput() bool
+}
+
 type ID string
 
+// ReaderHandle is a reader attached to a job, ID uniquely identifies the reader to CloseReader
+type ReaderHandle struct {
+	io.ReadCloser
+	ID     string
+	reason func() EndReason
+}
+
+// Reason returns why the output of the job ended, such that once Read returns io.EOF the reader can
+// tell a job which completed from a job which was stopped. Returns EndNone while the job is running.
+func (h *ReaderHandle) Reason() EndReason {
+	if h.reason == nil {
+		return EndNone
+	}
+	return h.reason()
+}
+
+// EndReason is why the output of a job ended, see ReaderHandle.Reason
+type EndReason int
+
+const (
+	// EndNone is the reason while the job is running
+	EndNone EndReason = iota
+	// EndCompleted is the reason when the job finished on its own by closing the writer
+	EndCompleted
+	// EndStopped is the reason when the job was stopped, such as by Stop or Close
+	EndStopped
+	// EndTimedOut is the reason when the job was stopped by RunOptions.IdleTimeout
+	EndTimedOut
+	// EndError is the reason when the job failed and was not restarted, the error is reported via Status.Err
+	EndError
+)
+
+func (e EndReason) String() string {
+	switch e {
+	case EndNone:
+		return "none"
+	case EndCompleted:
+		return "completed"
+	case EndStopped:
+		return "stopped"
+	case EndTimedOut:
+		return "timed out"
+	case EndError:
+		return "error"
+	}
+	return "unknown"
+}
+
+// Match is a line of job output found by Runner.Search
+type Match struct {
+	// Offset is the offset of the first byte of the line in the job output
+	Offset int
+	// Line is the matching line without the trailing newline
+	Line string
+}
+
+// RunOptions provides options for Runner.RunWithOptions
+type RunOptions struct {
+	// IdempotencyKey if not empty, is a caller supplied key which identifies the job. If a job
+	// with the same key is already running, the ID of the running job is returned instead of
+	// starting a new job. The key is released once the job stops.
+	IdempotencyKey string
+
+	// Restart is the policy used to restart the job if it fails
+	Restart RestartPolicy
+
+	// Barrier if not nil, delays starting the job until the channel is closed. This allows the caller
+	// to attach readers using the returned ID before the job writes any output. Since the job is
+	// started asynchronously, an error returned by Start fails the job instead of being returned
+	// by RunWithOptions.
+	Barrier <-chan struct{}
+
+	// Decoder if not nil, transcodes the output of the job to UTF-8 before it is stored, for jobs which
+	// write output in a legacy encoding such as DecodeLatin1. The decoder is called before Transform.
+	Decoder Decoder
+
+	// Transform if not nil, is called with each chunk of output read from the job and returns the bytes
+	// to store in its place, for instance to strip ANSI escape codes or redact secrets. Transform operates
+	// on each chunk as it was read from the job, not on lines, a chunk may contain many lines or only part
+	// of a line. Transform may modify and return the provided slice.
+	Transform func([]byte) []byte
+
+	// RetainAfterStop if not zero, is how long the runner retains the job and its output once the job
+	// has stopped. The job is then removed by a sweeper which runs every sweep interval, see
+	// WithSweepInterval. If zero, the job is retained until evicted.
+	RetainAfterStop time.Duration
+
+	// FreeOnStopIfUnread if true, discards the output of the job as soon as the job stops if no reader
+	// has attached to the job, freeing the memory of fire and forget jobs before the job is evicted.
+	// Readers created and snapshots taken once the output is discarded return ErrOutputDiscarded.
+	FreeOnStopIfUnread bool
+
+	// Group if not empty, writes the output of the job to the group with the provided name in addition
+	// to the output of the job. Each line written by a job in the group is prefixed with the ID of the job
+	// in square brackets, such that the output of all the jobs in the group interleaves by line and can
+	// be read with NewGroupReader. The group retains the most recent DefaultGroupRetention bytes, or fewer
+	// if the Retention of the job which creates the group is smaller. The output of the group counts toward
+	// TotalBufferedBytes and the budget of NewJobRunnerMemBudget.
+	Group string
+
+	// IdleTimeout if not zero, stops the job once it has written no output for the duration, such that
+	// ephemeral jobs exit once idle. The window begins when the job starts and is reset by each write,
+	// such that a job which never writes output is stopped once the first window elapses.
+	IdleTimeout time.Duration
+
+	// Priority of the job when the runner must evict a job to make room for a new job. Jobs with a
+	// lower priority are evicted first, among jobs of the same priority stopped jobs are evicted
+	// before running jobs, then the least recently used job is evicted first.
+	Priority int
+
+	// CollapseRepeats if true, collapses consecutive identical lines of output into a single line
+	// with a count suffix, such that a line repeated 42 times is stored as "line (x42)". Each line
+	// is stored once a different line is written or the job stops.
+	CollapseRepeats bool
+
+	// TimestampLines if true, prefixes each line of output with the time the line began to be written
+	// in RFC3339Nano format followed by a space, such as "2006-01-02T15:04:05.999999999Z line". Times are
+	// in UTC. The prefix is added after Transform and CollapseRepeats, and is not seen by ReadyWhen.
+	TimestampLines bool
+
+	// Mirrors are written a copy of all output as it is stored, for instance to also write the output
+	// to a file. Mirrors are written by the go routine which collects the output, such that a slow
+	// mirror delays storing further output. A mirror which returns an error is no longer written to.
+	Mirrors []io.Writer
+
+	// MirrorError if not nil, is called with a mirror and the error it returned before the mirror is
+	// removed from the active mirrors. The job and the other mirrors are unaffected.
+	MirrorError func(io.Writer, error)
+
+	// ReadyWhen if not nil, is called with each complete line of output, including the trailing newline,
+	// until it returns true. RunWithOptions does not return until ReadyWhen returns true, the context is
+	// cancelled, or the job stops. If the job stops before it is ready, the ID of the job is returned
+	// along with ErrNotReady.
+	ReadyWhen func([]byte) bool
+
+	// StartTimeout if not zero, is how long RunWithOptions waits for Start to return and for ReadyWhen to
+	// be satisfied, regardless of the context provided. If exceeded, the job is torn down as if it never
+	// existed and ErrStartTimeout is returned. Does not apply to a job which is queued.
+	StartTimeout time.Duration
+
+	// ValidateOnly if true, checks the job can start without retaining it, for instance to test the
+	// connection configured by a job. RunWithOptions waits for Start to return, and for ReadyWhen to
+	// return true if provided, then stops the job and removes it from the runner before returning.
+	// Returns the error Start failed with, or ErrNotReady if the job stopped before it was ready. The
+	// IdempotencyKey is ignored, such that a validated job is never confused with a running job.
+	ValidateOnly bool
+
+	// Sample if between 0 and 1, is the fraction of output chunks read from the job which are stored, for
+	// jobs which write so much output that only a representative sample is of interest. Chunks are sampled
+	// deterministically, with a Sample of 0.1 every tenth chunk is stored. Chunks do not align with lines,
+	// so the sampled output may contain partial lines. Status.BytesWritten reports the total bytes written.
+	Sample float64
+
+	// Retention chooses how much of the job output is retained, either RetainAll or RetainBytes(n).
+	// If not set, output is retained by the OutputStore configured for the runner.
+	Retention Retention
+
+	// Binary is informational and indicates the job writes binary output, it is reported via
+	// Status.Binary such that consumers know not to treat the output as text. The runner always
+	// treats job output as opaque bytes and never assumes UTF-8 or newline framing.
+	Binary bool
+}
+
+// RestartPolicy describes how a job which fails is restarted. A job fails when it closes
+// the writer provided to Start with an error, or Start returns an error when restarted.
+// Jobs stopped via Runner.Stop are never restarted.
+type RestartPolicy struct {
+	// MaxAttempts is the maximum number of times the job is restarted, zero disables restarts
+	MaxAttempts int
+
+	// Backoff is the time waited before the first restart, which doubles with each attempt
+	Backoff time.Duration
+
+	// MaxBackoff is the longest time waited before a restart, defaults to DefaultMaxBackoff if zero
+	MaxBackoff time.Duration
+}
+
+// DefaultMaxBackoff is the longest time waited before a restart unless configured by RestartPolicy.MaxBackoff
+const DefaultMaxBackoff = 5 * time.Minute
+
+// RestartOptions provides options for Runner.Restart
+type RestartOptions struct {
+	// PreserveOutput if true, retains the output of the previous run and appends the output of the
+	// next run after RestartSeparator, such that readers resuming by offset see continuous output.
+	// If false, or if the output of the previous run was discarded by RunOptions.FreeOnStopIfUnread,
+	// the next run starts with empty output.
+	PreserveOutput bool
+}
+
+// Retention is the amount of job output retained by the runner, which is the memory cost of the job
+// and the backlog a new reader receives. See RetainAll and RetainBytes.
+type Retention struct {
+	// bytes is the maximum number of bytes retained, or zero if all output is retained
+	bytes int
+	set   bool
+}
+
+// RetainAll retains all output written by the job for as long as the job is retained by the runner.
+// Memory grows without bound for jobs which write a lot of output.
+var RetainAll = Retention{set: true}
+
+// RetainBytes retains only the most recent n bytes of output written by the job, discarding the oldest
+// output once the limit is reached. Readers which fall behind by more than n bytes miss the discarded
+// output. Panics if n is not greater than zero.
+func RetainBytes(n int) Retention {
+	if n <= 0 {
+		panic(fmt.Sprintf("RetainBytes: A limit of %d bytes is not allowed", n))
+	}
+	return Retention{bytes: n, set: true}
+}
+
 // Runner provides a job running service which runs a single job. The job is provided a writer which
 // is buffered and stored for live monitoring or later retrieval. A client interested in a job may
 // request a reader, then close it, then request a new reader and resume monitoring the output
@@ -30,9 +333,40 @@ type ID string
 // monitoring later.
 type Runner interface {
 	// Run the provided job, returning an ID which can be used to track the status of a job.
-	// Returns an error if the job failed to start of context was cancelled.
+	// Returns an error if the job failed to start of context was cancelled. If Start panics, the panic
+	// is recovered and the ID of the stopped job is returned along with an error wrapping ErrJobPanicked.
 	Run(context.Context, Job) (ID, error)
 
+	// RunWithOptions is identical to Run but allows the caller to provide options for the job.
+	RunWithOptions(context.Context, Job, RunOptions) (ID, error)
+
+	// RunWithID is identical to Run but uses the caller supplied ID for the job instead of generating one.
+	// Returns ErrJobExists if a job with the same ID already exists.
+	RunWithID(context.Context, ID, Job) error
+
+	// RunInGroup is identical to Run but writes the output of the job to the named group, creating the
+	// group if needed. Equivalent to RunWithOptions with RunOptions.Group.
+	RunInGroup(ctx context.Context, group string, job Job) (ID, error)
+
+	// RunCancelable is identical to Run but also returns a function which stops the job, equivalent to
+	// calling Stop. The function may be called multiple times, and has no effect once the job has stopped.
+	RunCancelable(context.Context, Job) (ID, context.CancelFunc, error)
+
+	// RunAfter is identical to Run but defers starting the job until the job identified by after has
+	// stopped. The ID of the job is returned immediately, and the job is Pending until started. If the
+	// after job doesn't exist or has already stopped, the job is started immediately.
+	RunAfter(ctx context.Context, after ID, job Job) (ID, error)
+
+	// RunAll runs all the provided jobs, returning the IDs of the jobs in the same order. If any job fails
+	// to start, all the jobs already started are stopped and an error is returned, such that either all
+	// the jobs are running or none of them are.
+	RunAll(context.Context, []Job) ([]ID, error)
+
+	// Restart starts a stopped job again under the same ID, with the same RunOptions except for the
+	// Barrier. Returns ErrJobRunning if the job is still running or ErrJobNotFound if the job doesn't
+	// exist. If the job fails to start, the error is returned and the job remains stopped.
+	Restart(ctx context.Context, id ID, opts RestartOptions) error
+
 	// NewReader returns an io.Reader which can be read to get the most current output from a running job.
 	// Job runner supports multiple readers for the same job. In this way, multiple remote clients may monitor
 	// the output of the job simultaneously. Reader will return io.EOF when the job is no longer running and all
@@ -40,15 +374,153 @@ type Runner interface {
 	// free up resources.
 	NewReader(ID) (io.ReadCloser, error)
 
+	// NewReaderDone is identical to NewReader but also returns a channel which is closed once the reader
+	// is finished, which occurs when the job is no longer running and all output has been delivered
+	// to the reader, or the reader has been closed by the caller.
+	NewReaderDone(ID) (io.ReadCloser, <-chan struct{}, error)
+
+	// NewReaderTimeout is identical to NewReader but if the reader does not accept all the output
+	// already written by the job within the timeout, the reader is closed and reads return
+	// ErrReaderTimeout. This avoids slow or stalled readers tying up resources indefinitely.
+	NewReaderTimeout(ID, time.Duration) (io.ReadCloser, error)
+
+	// NewReaderLimited is identical to NewReader but delivers output to the reader no faster than the
+	// provided number of bytes per second. The limit applies only to this reader, the job and other
+	// readers are unaffected.
+	NewReaderLimited(ID, int) (io.ReadCloser, error)
+
+	// NewResumableReader is identical to NewReader but begins reading from the provided offset, and
+	// also returns a function which reports the offset just past the last byte delivered to the reader.
+	// If the reader disconnects, providing that offset to a new call to NewResumableReader resumes
+	// reading without repeating any output. An offset of 0 reads all output from the beginning.
+	NewResumableReader(ID, int) (io.ReadCloser, func() int, error)
+
+	// NewRecordReader returns a reader which returns the output of the job one complete line at a time,
+	// flagging lines which are not valid JSON. Intended for jobs which emit one JSON record per line.
+	NewRecordReader(ID, RecordOptions) (*RecordReader, error)
+
+	// Input returns a writer which writes input to the running job, closing the writer closes the input
+	// of the job. Writes block until the job reads the input, and fail once the job has stopped. Returns
+	// ErrNoInput if the job doesn't implement InputStarter or doesn't accept input, see InputAccepter, or
+	// ErrJobNotRunning if the job has stopped.
+	Input(ID) (io.WriteCloser, error)
+
+	// Snapshot returns a copy of all the output retained for the job at the time of the call, and the offset
+	// just past the returned output, which can be provided to NewResumableReader to read any output written
+	// after the snapshot. Returns ErrJobNotFound if the job doesn't exist.
+	Snapshot(ID) ([]byte, int, error)
+
+	// OutputHash returns the SHA-256 digest of all the output stored for the job so far, including output
+	// the store no longer retains, such that archived output can be verified against what the job wrote.
+	// Returns ErrJobNotFound if the job doesn't exist.
+	OutputHash(ID) ([]byte, error)
+
+	// Mark records the current offset of the job output under the provided name, replacing any previous
+	// marker with the same name, and returns the offset. The offset can be provided to NewResumableReader
+	// to read the output written after the marker.
+	Mark(ID, string) (int, error)
+
+	// Markers returns a copy of all the markers recorded for the job by Mark
+	Markers(ID) (map[string]int, error)
+
+	// Search scans the output retained for the job in the order it was written and returns up to max lines
+	// which match the provided regular expression. A max of 0 returns all matching lines. Returns
+	// ErrJobNotFound if the job doesn't exist.
+	Search(id ID, re *regexp.Regexp, max int) ([]Match, error)
+
+	// BufferLen returns the number of bytes of output currently retained for the job, which is the size of
+	// the backlog a new reader would receive. Returns ErrJobNotFound if the job doesn't exist.
+	BufferLen(ID) (int, error)
+
+	// TotalBufferedBytes returns the number of bytes of output currently retained across all jobs, which
+	// is the sum of BufferLen for every job plus the output retained by groups, see RunOptions.Group.
+	// Useful to size the budget given to NewJobRunnerMemBudget.
+	TotalBufferedBytes() int
+
+	// CacheStats returns the number of lookups of a job by ID which found the job (hits) or did not
+	// (misses), and the number of jobs evicted to make room for new jobs or output, such as when the
+	// capacity of the runner or the budget of NewJobRunnerMemBudget is exceeded. Useful to size the runner.
+	CacheStats() (hits, misses, evictions int)
+
+	// NewGroupReader returns a reader of the interleaved output of all the jobs in the named group,
+	// beginning with the oldest output the group retains. The reader returns io.EOF once no job in
+	// the group is running. A group exists while the runner holds any job in the group, returns
+	// ErrGroupNotFound otherwise.
+	NewGroupReader(group string) (io.ReadCloser, error)
+
+	// NewReaderHandle is identical to NewReader but returns a handle which includes the unique ID
+	// of the reader, such that the reader can be disconnected by CloseReader.
+	NewReaderHandle(ID) (*ReaderHandle, error)
+
+	// CloseReader forcibly disconnects the reader with the provided reader ID from the job, subsequent
+	// reads by the reader return ErrReaderClosed. Returns ErrReaderNotFound if no such reader is attached.
+	CloseReader(id ID, readerID string) error
+
+	// ReaderCount returns the number of readers currently attached to the job. A reader is attached until
+	// it is closed, or has read all the output of a job which is no longer running.
+	ReaderCount(ID) (int, error)
+
 	// Stop a currently running job, returns an error if the context was cancelled before the job stopped.
 	Stop(context.Context, ID) error
 
-	// Close all currently running jobs
+	// TryStop is identical to Stop but returns ErrWouldBlock immediately if another operation, such as
+	// a Stop which is waiting on a job to stop, holds the runner, or if the job does not stop promptly.
+	// A job which does not stop promptly continues to stop in the background. Useful for handlers
+	// which must not wait.
+	TryStop(ID) error
+
+	// StopGraceful stops a currently running job, waiting up to the grace period for the job to stop.
+	// If the job has not stopped within the grace period and implements Killer, the job is killed.
+	StopGraceful(ctx context.Context, id ID, grace time.Duration) error
+
+	// Flush wakes all readers of the job immediately, such that they receive all the output
+	// collected so far without waiting for the window configured by WithBroadcastInterval.
+	// Flush has no effect if the runner does not batch broadcasts.
+	Flush(ID) error
+
+	// Remove a stopped job and its output from the runner. Returns ErrJobRunning
+	// if the job is still running, or ErrJobNotFound if the job doesn't exist.
+	Remove(ID) error
+
+	// Pause the capture of output from a running job. Output written by the job while paused is
+	// discarded and never delivered to readers. The job itself continues to run.
+	Pause(ID) error
+
+	// Resume the capture of output from a paused job.
+	Resume(ID) error
+
+	// Events subscribes to lifecycle events for all jobs, returning a channel of events and a function
+	// which unsubscribes and closes the channel. Events are dropped if the subscriber falls more than
+	// EventBufferSize events behind, such that slow subscribers never block the runner. No events are
+	// sent for a job until Start returns without error, such as a job which fails to start or is queued.
+	Events() (<-chan Event, func())
+
+	// Close stops all currently running jobs and the background sweeper, then waits until all attached
+	// readers have read the remaining output or been closed, or the context is cancelled. Once closed,
+	// new readers can not be created and NewReader returns ErrRunnerClosed.
 	Close(context.Context) error
 
 	// Status returns the status of the job, returns false if the job doesn't exist
 	Status(ID) (Status, bool)
 
+	// StatusMany returns the status of each of the provided jobs which exist, keyed by ID.
+	// Jobs which don't exist are omitted from the result.
+	StatusMany([]ID) map[ID]Status
+
 	// List all jobs
 	List() []Status
+
+	// ListPage returns a page of at most limit jobs starting at offset, along with the total number of
+	// jobs. Jobs are ordered by the time they started, such that consecutive pages list every job once
+	// provided no jobs are added or removed between calls. A limit of 0 returns all jobs after offset.
+	ListPage(offset, limit int) ([]Status, int)
+
+	// StatusCtx is identical to Status but returns the context error if the job is busy, for instance
+	// storing output, and the context is cancelled before the status could be retrieved. Returns
+	// ErrJobNotFound if the job doesn't exist.
+	StatusCtx(context.Context, ID) (Status, error)
+
+	// ListCtx is identical to List but returns the context error if a job is busy and
+	// the context is cancelled before the list could be retrieved.
+	ListCtx(context.Context) ([]Status, error)
 }
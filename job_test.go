@@ -2,9 +2,17 @@ package steve_test
 
 import (
 	"bufio"
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"errors"
 	"fmt"
 	"io"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -13,6 +21,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/thrawn01/steve"
+	"go.uber.org/goleak"
 )
 
 type testJob struct {
@@ -42,6 +51,149 @@ func (t *testJob) Stop(ctx context.Context) error {
 	return nil
 }
 
+// linesJob writes a fixed number of lines when started
+type linesJob struct {
+	count int
+}
+
+func (l *linesJob) Start(ctx context.Context, writer io.Writer) error {
+	for i := 0; i < l.count; i++ {
+		_, _ = fmt.Fprintf(writer, "line: %d\n", i)
+	}
+	return nil
+}
+
+func (l *linesJob) Stop(ctx context.Context) error {
+	return nil
+}
+
+var errFailedStart = errors.New("failed to start")
+
+// failJob writes some output then fails to start
+type failJob struct{}
+
+func (f *failJob) Start(ctx context.Context, writer io.Writer) error {
+	_, _ = fmt.Fprintf(writer, "Job Start\n")
+	return errFailedStart
+}
+
+func (f *failJob) Stop(ctx context.Context) error {
+	return nil
+}
+
+var errFailedStop = errors.New("failed to stop")
+
+// stopFailJob runs until the writer is closed, but always fails to stop
+type stopFailJob struct{}
+
+func (s *stopFailJob) Start(ctx context.Context, writer io.Writer) error {
+	_, _ = fmt.Fprintf(writer, "Job Start\n")
+	return nil
+}
+
+func (s *stopFailJob) Stop(ctx context.Context) error {
+	return errFailedStop
+}
+
+// flakyJob fails until it has been started 'failures' times
+type flakyJob struct {
+	failures int64
+	starts   int64
+}
+
+func (f *flakyJob) Start(ctx context.Context, writer io.Writer) error {
+	n := atomic.AddInt64(&f.starts, 1)
+	_, _ = fmt.Fprintf(writer, "attempt: %d\n", n)
+	if n <= f.failures {
+		return writer.(*io.PipeWriter).CloseWithError(errors.New("job failed"))
+	}
+	return writer.(*io.PipeWriter).Close()
+}
+
+func (f *flakyJob) Stop(ctx context.Context) error {
+	return nil
+}
+
+// timedJob writes the provided data then completes after the provided duration
+type timedJob struct {
+	data     []byte
+	duration time.Duration
+}
+
+func (j *timedJob) Start(ctx context.Context, writer io.Writer) error {
+	_, _ = writer.Write(j.data)
+	go func() {
+		time.Sleep(j.duration)
+		_ = writer.(*io.PipeWriter).Close()
+	}()
+	return nil
+}
+
+func (j *timedJob) Stop(ctx context.Context) error {
+	return nil
+}
+
+// writerJob writes any lines sent on the channel to the job output
+type writerJob struct {
+	lines chan string
+	done  chan struct{}
+}
+
+func newWriterJob() *writerJob {
+	return &writerJob{
+		lines: make(chan string),
+		done:  make(chan struct{}),
+	}
+}
+
+func (w *writerJob) Start(ctx context.Context, writer io.Writer) error {
+	go func() {
+		for line := range w.lines {
+			_, _ = fmt.Fprintln(writer, line)
+		}
+		close(w.done)
+	}()
+	return nil
+}
+
+func (w *writerJob) Stop(ctx context.Context) error {
+	return nil
+}
+
+// Write the line to the job output, returning once the line has been written
+func (w *writerJob) Write(line string) {
+	w.lines <- line
+}
+
+// ctxJob records the context provided to Start
+type ctxJob struct {
+	ctx context.Context
+}
+
+func (c *ctxJob) Start(ctx context.Context, writer io.Writer) error {
+	c.ctx = ctx
+	return nil
+}
+
+func (c *ctxJob) Stop(ctx context.Context) error {
+	return nil
+}
+
+// blockingJob blocks in Start until the context is cancelled
+type blockingJob struct {
+	started chan struct{}
+}
+
+func (b *blockingJob) Start(ctx context.Context, writer io.Writer) error {
+	close(b.started)
+	<-ctx.Done()
+	return nil
+}
+
+func (b *blockingJob) Stop(ctx context.Context) error {
+	return nil
+}
+
 func TestRunner(t *testing.T) {
 	runner := steve.NewJobRunner(20)
 	require.NotNil(t, runner)
@@ -107,3 +259,2764 @@ func TestRunner(t *testing.T) {
 	})
 
 }
+
+func TestNewReaderDone(t *testing.T) {
+	runner := steve.NewJobRunner(20)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	id, err := runner.Run(ctx, &linesJob{count: 10})
+	require.NoError(t, err)
+
+	r, done, err := runner.NewReaderDone(id)
+	require.NoError(t, err)
+
+	out := make(chan []byte)
+	go func() {
+		b, _ := io.ReadAll(r)
+		out <- b
+	}()
+
+	// Done should not close while the job is running
+	select {
+	case <-done:
+		t.Fatal("done closed before the job was stopped")
+	case <-time.After(time.Millisecond * 100):
+	}
+
+	require.NoError(t, runner.Stop(ctx, id))
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		t.Fatal("timed out waiting for done to close")
+	}
+	b := <-out
+	assert.Contains(t, string(b), "line: 0\n")
+	assert.Contains(t, string(b), "line: 9\n")
+
+	// A reader of a stopped job is done once it has been drained
+	r, done, err = runner.NewReaderDone(id)
+	require.NoError(t, err)
+	select {
+	case <-done:
+		t.Fatal("done closed before the reader was drained")
+	default:
+	}
+	drained, err := io.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, b, drained)
+	select {
+	case <-done:
+	default:
+		t.Fatal("done not closed after the reader was drained")
+	}
+}
+
+func TestRunIdempotencyKey(t *testing.T) {
+	runner := steve.NewJobRunner(20)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	opts := steve.RunOptions{IdempotencyKey: "build-1234"}
+	id, err := runner.RunWithOptions(ctx, &linesJob{count: 1}, opts)
+	require.NoError(t, err)
+
+	// The second run with the same key should return the running job
+	dup, err := runner.RunWithOptions(ctx, &linesJob{count: 1}, opts)
+	require.NoError(t, err)
+	assert.Equal(t, id, dup)
+	assert.Len(t, runner.List(), 1)
+
+	// Once the job is stopped, the key is released
+	require.NoError(t, runner.Stop(ctx, id))
+	testutil.UntilPass(t, 10, time.Millisecond*100, func(t testutil.TestingT) {
+		s, ok := runner.Status(id)
+		require.True(t, ok)
+		assert.False(t, s.Running)
+	})
+
+	next, err := runner.RunWithOptions(ctx, &linesJob{count: 1}, opts)
+	require.NoError(t, err)
+	assert.NotEqual(t, id, next)
+	assert.Len(t, runner.List(), 2)
+}
+
+func TestRunStartError(t *testing.T) {
+	runner := steve.NewJobRunner(20)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	before := runtime.NumGoroutine()
+
+	id, err := runner.RunWithOptions(ctx, &failJob{}, steve.RunOptions{IdempotencyKey: "fail"})
+	require.EqualError(t, err, "failed to start")
+	assert.Empty(t, id)
+
+	// The job should not remain in the cache
+	assert.Len(t, runner.List(), 0)
+
+	// The monitor go routines should exit
+	testutil.UntilPass(t, 10, time.Millisecond*100, func(t testutil.TestingT) {
+		assert.LessOrEqual(t, runtime.NumGoroutine(), before)
+	})
+
+	// The idempotency key should be released
+	id, err = runner.RunWithOptions(ctx, &linesJob{count: 1}, steve.RunOptions{IdempotencyKey: "fail"})
+	require.NoError(t, err)
+	assert.NotEmpty(t, id)
+}
+
+func TestRunRestartPolicy(t *testing.T) {
+	runner := steve.NewJobRunner(20)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	job := &flakyJob{failures: 2}
+	id, err := runner.RunWithOptions(ctx, job, steve.RunOptions{
+		Restart: steve.RestartPolicy{MaxAttempts: 5, Backoff: time.Millisecond * 10},
+	})
+	require.NoError(t, err)
+
+	// The job should fail twice, then succeed on the third attempt
+	testutil.UntilPass(t, 20, time.Millisecond*100, func(t testutil.TestingT) {
+		s, ok := runner.Status(id)
+		require.True(t, ok)
+		assert.False(t, s.Running)
+		assert.Equal(t, 3, s.Attempts)
+	})
+	assert.Equal(t, int64(3), atomic.LoadInt64(&job.starts))
+
+	r, err := runner.NewReader(id)
+	require.NoError(t, err)
+	out, err := io.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, "attempt: 1\nattempt: 2\nattempt: 3\n", string(out))
+}
+
+func TestRunRestartDisabledByStop(t *testing.T) {
+	runner := steve.NewJobRunner(20)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	job := &flakyJob{failures: 10}
+	id, err := runner.RunWithOptions(ctx, job, steve.RunOptions{
+		Restart: steve.RestartPolicy{MaxAttempts: 10, Backoff: time.Minute},
+	})
+	require.NoError(t, err)
+
+	// Stopping the job while waiting to restart should prevent the restart
+	require.NoError(t, runner.Stop(ctx, id))
+	testutil.UntilPass(t, 10, time.Millisecond*100, func(t testutil.TestingT) {
+		s, ok := runner.Status(id)
+		require.True(t, ok)
+		assert.False(t, s.Running)
+		assert.Equal(t, 1, s.Attempts)
+	})
+	assert.Equal(t, int64(1), atomic.LoadInt64(&job.starts))
+}
+
+func TestRunBarrier(t *testing.T) {
+	runner := steve.NewJobRunner(20)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	barrier := make(chan struct{})
+	id, err := runner.RunWithOptions(ctx, &linesJob{count: 5}, steve.RunOptions{Barrier: barrier})
+	require.NoError(t, err)
+
+	r, err := runner.NewReader(id)
+	require.NoError(t, err)
+	buf := bufio.NewReader(r)
+
+	// Release the barrier only after the reader has attached
+	close(barrier)
+
+	// The reader should receive every line from byte 0
+	for i := 0; i < 5; i++ {
+		line, err := buf.ReadString('\n')
+		require.NoError(t, err)
+		assert.Equal(t, fmt.Sprintf("line: %d\n", i), line)
+	}
+
+	require.NoError(t, runner.Stop(ctx, id))
+	_, err = buf.ReadString('\n')
+	assert.ErrorIs(t, err, io.EOF)
+}
+
+func TestStatusThroughput(t *testing.T) {
+	runner := steve.NewJobRunner(20)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	id, err := runner.Run(ctx, &timedJob{data: make([]byte, 10_000), duration: time.Millisecond * 500})
+	require.NoError(t, err)
+
+	// Throughput is available while the job is running
+	s, ok := runner.Status(id)
+	require.True(t, ok)
+	assert.True(t, s.Running)
+	assert.Greater(t, s.Throughput, 0.0)
+
+	testutil.UntilPass(t, 20, time.Millisecond*100, func(t testutil.TestingT) {
+		s, ok = runner.Status(id)
+		require.True(t, ok)
+		assert.False(t, s.Running)
+	})
+
+	// 10,000 bytes over 500ms is roughly 20,000 bytes/sec
+	assert.InDelta(t, 20_000, s.Throughput, 4_000)
+
+	// Throughput of a stopped job does not change over time
+	time.Sleep(time.Millisecond * 100)
+	again, _ := runner.Status(id)
+	assert.Equal(t, s.Throughput, again.Throughput)
+}
+
+func TestPauseResume(t *testing.T) {
+	runner := steve.NewJobRunner(20)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	job := newWriterJob()
+	id, err := runner.Run(ctx, job)
+	require.NoError(t, err)
+
+	r, err := runner.NewReader(id)
+	require.NoError(t, err)
+	buf := bufio.NewReader(r)
+
+	job.Write("before pause")
+	line, err := buf.ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, "before pause\n", line)
+
+	require.NoError(t, runner.Pause(id))
+	s, _ := runner.Status(id)
+	assert.True(t, s.Paused)
+
+	job.Write("while paused")
+	// Allow time for the monitor to receive the write while paused
+	time.Sleep(time.Millisecond * 100)
+
+	require.NoError(t, runner.Resume(id))
+	s, _ = runner.Status(id)
+	assert.False(t, s.Paused)
+
+	job.Write("after resume")
+	line, err = buf.ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, "after resume\n", line)
+
+	require.NoError(t, runner.Stop(ctx, id))
+	testutil.UntilPass(t, 10, time.Millisecond*100, func(t testutil.TestingT) {
+		s, _ := runner.Status(id)
+		assert.False(t, s.Running)
+	})
+
+	// Output written while paused is not stored
+	r, err = runner.NewReader(id)
+	require.NoError(t, err)
+	out, err := io.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, "before pause\nafter resume\n", string(out))
+
+	assert.ErrorIs(t, runner.Pause(id), steve.ErrJobNotRunning)
+	assert.ErrorIs(t, runner.Resume("unknown"), steve.ErrJobNotFound)
+}
+
+func TestBinaryOutput(t *testing.T) {
+	runner := steve.NewJobRunner(20)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	// Every byte value, including nulls, invalid UTF-8 and no newlines
+	var data []byte
+	for i := 0; i < 10; i++ {
+		for b := 0; b < 256; b++ {
+			data = append(data, byte(b))
+		}
+	}
+
+	id, err := runner.RunWithOptions(ctx, &timedJob{data: data, duration: time.Millisecond * 200},
+		steve.RunOptions{Binary: true})
+	require.NoError(t, err)
+
+	s, _ := runner.Status(id)
+	assert.True(t, s.Binary)
+
+	// Live reader
+	r, err := runner.NewReader(id)
+	require.NoError(t, err)
+	out, err := io.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, data, out)
+
+	// Reader of the stopped job
+	r, err = runner.NewReader(id)
+	require.NoError(t, err)
+	out, err = io.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, data, out)
+}
+
+func TestRunAll(t *testing.T) {
+	runner := steve.NewJobRunner(20)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	ids, err := runner.RunAll(ctx, []steve.Job{&linesJob{count: 1}, &linesJob{count: 2}})
+	require.NoError(t, err)
+	require.Len(t, ids, 2)
+	for _, id := range ids {
+		s, ok := runner.Status(id)
+		require.True(t, ok)
+		assert.True(t, s.Running)
+	}
+
+	// When the third job fails to start, the first two are stopped
+	ids, err = runner.RunAll(ctx, []steve.Job{newWriterJob(), newWriterJob(), &failJob{}})
+	require.Error(t, err)
+	assert.ErrorIs(t, err, errFailedStart)
+	assert.Nil(t, ids)
+
+	testutil.UntilPass(t, 10, time.Millisecond*100, func(t testutil.TestingT) {
+		var running int
+		for _, s := range runner.List() {
+			if s.Running {
+				running++
+			}
+		}
+		// Only the jobs from the first successful RunAll are running
+		assert.Equal(t, 2, running)
+	})
+	assert.Len(t, runner.List(), 4)
+}
+
+func TestRunWithID(t *testing.T) {
+	runner := steve.NewJobRunner(20)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	dup := &flakyJob{}
+	require.NoError(t, runner.RunWithID(ctx, "job-1", newWriterJob()))
+	s, ok := runner.Status("job-1")
+	require.True(t, ok)
+	assert.Equal(t, steve.ID("job-1"), s.ID)
+	assert.True(t, s.Running)
+
+	// A second job with the same ID is never started
+	err := runner.RunWithID(ctx, "job-1", dup)
+	assert.ErrorIs(t, err, steve.ErrJobExists)
+	assert.Equal(t, int64(0), atomic.LoadInt64(&dup.starts))
+	assert.Len(t, runner.List(), 1)
+
+	// The existing job is unaffected
+	s, ok = runner.Status("job-1")
+	require.True(t, ok)
+	assert.True(t, s.Running)
+}
+
+func TestJobContext(t *testing.T) {
+	runner := steve.NewJobRunner(20)
+	type key struct{}
+
+	ctx, cancel := context.WithTimeout(context.WithValue(context.Background(), key{}, "value"), time.Second*5)
+	job := &ctxJob{}
+	id, err := runner.Run(ctx, job)
+	require.NoError(t, err)
+
+	// The job context carries the values of the run context
+	assert.Equal(t, "value", job.ctx.Value(key{}))
+
+	// Cancelling the run context does not stop the job
+	cancel()
+	time.Sleep(time.Millisecond * 100)
+	assert.NoError(t, job.ctx.Err())
+	s, _ := runner.Status(id)
+	assert.True(t, s.Running)
+
+	// Stopping the job cancels the job context
+	require.NoError(t, runner.Stop(context.Background(), id))
+	assert.ErrorIs(t, job.ctx.Err(), context.Canceled)
+}
+
+func TestJobContextCancelledDuringStartup(t *testing.T) {
+	runner := steve.NewJobRunner(20)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	// A job which doesn't return from Start until the context is cancelled
+	job := &blockingJob{started: make(chan struct{})}
+	go func() {
+		<-job.started
+		cancel()
+	}()
+
+	_, err := runner.Run(ctx, job)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Len(t, runner.List(), 0)
+}
+
+func TestReaderCount(t *testing.T) {
+	runner := steve.NewJobRunner(20)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	_, err := runner.ReaderCount("unknown")
+	assert.ErrorIs(t, err, steve.ErrJobNotFound)
+
+	job := newWriterJob()
+	id, err := runner.Run(ctx, job)
+	require.NoError(t, err)
+
+	count, err := runner.ReaderCount(id)
+	require.NoError(t, err)
+	assert.Equal(t, 0, count)
+
+	r1, err := runner.NewReader(id)
+	require.NoError(t, err)
+	r2, err := runner.NewReader(id)
+	require.NoError(t, err)
+
+	count, err = runner.ReaderCount(id)
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	// Closing the pipe detaches the reader
+	require.NoError(t, r1.Close())
+	testutil.UntilPass(t, 10, time.Millisecond*100, func(t testutil.TestingT) {
+		count, err := runner.ReaderCount(id)
+		require.NoError(t, err)
+		assert.Equal(t, 1, count)
+	})
+
+	// The remaining reader detaches once it has read all the output of the stopped job
+	job.Write("hello")
+	buf := bufio.NewReader(r2)
+	line, err := buf.ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, "hello\n", line)
+	require.NoError(t, runner.Stop(ctx, id))
+	_, err = buf.ReadString('\n')
+	assert.ErrorIs(t, err, io.EOF)
+	testutil.UntilPass(t, 10, time.Millisecond*100, func(t testutil.TestingT) {
+		count, err := runner.ReaderCount(id)
+		require.NoError(t, err)
+		assert.Equal(t, 0, count)
+	})
+
+	// Readers of a stopped job are counted until drained or closed
+	r3, err := runner.NewReader(id)
+	require.NoError(t, err)
+	count, _ = runner.ReaderCount(id)
+	assert.Equal(t, 1, count)
+	require.NoError(t, r3.Close())
+	count, _ = runner.ReaderCount(id)
+	assert.Equal(t, 0, count)
+}
+
+func TestNewReaderTimeout(t *testing.T) {
+	runner := steve.NewJobRunner(20)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	id, err := runner.Run(ctx, &linesJob{count: 10_000})
+	require.NoError(t, err)
+
+	t.Run("Accepted", func(t *testing.T) {
+		r, err := runner.NewReaderTimeout(id, time.Second)
+		require.NoError(t, err)
+		buf := bufio.NewReader(r)
+		for i := 0; i < 10_000; i++ {
+			_, err := buf.ReadString('\n')
+			require.NoError(t, err)
+		}
+		require.NoError(t, r.Close())
+	})
+
+	t.Run("Expired", func(t *testing.T) {
+		r, err := runner.NewReaderTimeout(id, time.Millisecond*100)
+		require.NoError(t, err)
+
+		// Read a small part of the backlog, then stop reading
+		_, err = r.Read(make([]byte, 10))
+		require.NoError(t, err)
+
+		// The reader go routine should exit after the deadline
+		testutil.UntilPass(t, 10, time.Millisecond*100, func(t testutil.TestingT) {
+			count, err := runner.ReaderCount(id)
+			require.NoError(t, err)
+			assert.Equal(t, 0, count)
+		})
+
+		_, err = io.ReadAll(r)
+		assert.ErrorIs(t, err, steve.ErrReaderTimeout)
+	})
+
+	require.NoError(t, runner.Stop(ctx, id))
+}
+
+func TestRunReadyWhen(t *testing.T) {
+	runner := steve.NewJobRunner(20)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	job := newWriterJob()
+	returned := make(chan error)
+	var id steve.ID
+	go func() {
+		var err error
+		id, err = runner.RunWithOptions(ctx, job, steve.RunOptions{
+			ReadyWhen: func(line []byte) bool {
+				return string(line) == "ready\n"
+			},
+		})
+		returned <- err
+	}()
+
+	job.Write("booting...")
+	select {
+	case <-returned:
+		t.Fatal("Run returned before the job was ready")
+	case <-time.After(time.Millisecond * 100):
+	}
+
+	job.Write("ready")
+	select {
+	case err := <-returned:
+		require.NoError(t, err)
+	case <-ctx.Done():
+		t.Fatal("Run did not return once the job was ready")
+	}
+
+	r, err := runner.NewReader(id)
+	require.NoError(t, err)
+	line, err := bufio.NewReader(r).ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, "booting...\n", line)
+	require.NoError(t, runner.Stop(ctx, id))
+
+	// A job which stops before it is ready
+	id, err = runner.RunWithOptions(ctx, steve.NewExecJob("echo", "booting..."), steve.RunOptions{
+		ReadyWhen: func(line []byte) bool {
+			return string(line) == "ready\n"
+		},
+	})
+	assert.ErrorIs(t, err, steve.ErrNotReady)
+	s, ok := runner.Status(id)
+	require.True(t, ok)
+	assert.False(t, s.Running)
+}
+
+func TestWithBroadcastInterval(t *testing.T) {
+	runner := steve.NewJobRunner(20, steve.WithBroadcastInterval(time.Millisecond*10))
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	job := newWriterJob()
+	id, err := runner.Run(ctx, job)
+	require.NoError(t, err)
+
+	r, err := runner.NewReader(id)
+	require.NoError(t, err)
+	defer r.Close()
+
+	// A single write is delivered once the window has elapsed
+	job.Write("one")
+	line, err := bufio.NewReader(r).ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, "one\n", line)
+
+	// Many writes within the window are not lost
+	for i := 0; i < 1_000; i++ {
+		job.Write(fmt.Sprintf("line: %d", i))
+	}
+	close(job.lines)
+	<-job.done
+	require.NoError(t, runner.Stop(ctx, id))
+
+	out, err := io.ReadAll(r)
+	require.NoError(t, err)
+	var expected bytes.Buffer
+	for i := 0; i < 1_000; i++ {
+		_, _ = fmt.Fprintf(&expected, "line: %d\n", i)
+	}
+	assert.Equal(t, expected.String(), string(out))
+}
+
+func BenchmarkBroadcast(b *testing.B) {
+	for _, bench := range []struct {
+		name     string
+		interval time.Duration
+	}{
+		{name: "EveryWrite", interval: 0},
+		{name: "Coalesced", interval: time.Millisecond * 10},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			runner := steve.NewJobRunner(20, steve.WithBroadcastInterval(bench.interval))
+			ctx := context.Background()
+			b.ReportAllocs()
+
+			for i := 0; i < b.N; i++ {
+				job := newWriterJob()
+				id, err := runner.Run(ctx, job)
+				require.NoError(b, err)
+
+				var wg sync.WaitGroup
+				for n := 0; n < 50; n++ {
+					r, err := runner.NewReader(id)
+					require.NoError(b, err)
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						_, _ = io.Copy(io.Discard, r)
+						_ = r.Close()
+					}()
+				}
+
+				for l := 0; l < 1_000; l++ {
+					job.Write("a short line of output")
+				}
+				close(job.lines)
+				<-job.done
+				require.NoError(b, runner.Stop(ctx, id))
+				wg.Wait()
+			}
+		})
+	}
+}
+
+func TestStopError(t *testing.T) {
+	runner := steve.NewJobRunner(20)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	id, err := runner.Run(ctx, &stopFailJob{})
+	require.NoError(t, err)
+
+	err = runner.Stop(ctx, id)
+	assert.ErrorIs(t, err, errFailedStop)
+
+	// The job is no longer running, even though the job failed to stop
+	testutil.UntilPass(t, 10, time.Millisecond*100, func(t testutil.TestingT) {
+		s, ok := runner.Status(id)
+		assert.True(t, ok)
+		assert.False(t, s.Running)
+	})
+
+	id, err = runner.Run(ctx, &stopFailJob{})
+	require.NoError(t, err)
+
+	err = runner.StopGraceful(ctx, id, time.Millisecond*100)
+	assert.ErrorIs(t, err, errFailedStop)
+	testutil.UntilPass(t, 10, time.Millisecond*100, func(t testutil.TestingT) {
+		s, ok := runner.Status(id)
+		assert.True(t, ok)
+		assert.False(t, s.Running)
+	})
+}
+
+func TestJobWithoutOutput(t *testing.T) {
+	runner := steve.NewJobRunner(20)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	// A job which never writes is running until stopped
+	id, err := runner.Run(ctx, newWriterJob())
+	require.NoError(t, err)
+
+	s, ok := runner.Status(id)
+	require.True(t, ok)
+	assert.True(t, s.Running)
+
+	require.NoError(t, runner.Stop(ctx, id))
+	testutil.UntilPass(t, 10, time.Millisecond*100, func(t testutil.TestingT) {
+		s, ok := runner.Status(id)
+		assert.True(t, ok)
+		assert.False(t, s.Running)
+		assert.False(t, s.Stopped.IsZero())
+	})
+
+	r, err := runner.NewReader(id)
+	require.NoError(t, err)
+	out, err := io.ReadAll(r)
+	require.NoError(t, err)
+	assert.Empty(t, out)
+	require.NoError(t, r.Close())
+
+	// A job which completes without writing
+	id, err = runner.Run(ctx, steve.NewExecJob("true"))
+	require.NoError(t, err)
+
+	r, err = runner.NewReader(id)
+	require.NoError(t, err)
+	out, err = io.ReadAll(r)
+	require.NoError(t, err)
+	assert.Empty(t, out)
+	require.NoError(t, r.Close())
+
+	testutil.UntilPass(t, 10, time.Millisecond*100, func(t testutil.TestingT) {
+		s, ok := runner.Status(id)
+		assert.True(t, ok)
+		assert.False(t, s.Running)
+		if assert.NotNil(t, s.ExitCode) {
+			assert.Equal(t, 0, *s.ExitCode)
+		}
+	})
+}
+
+func TestNewResumableReader(t *testing.T) {
+	runner := steve.NewJobRunner(20)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	job := newWriterJob()
+	id, err := runner.Run(ctx, job)
+	require.NoError(t, err)
+
+	r, offset, err := runner.NewResumableReader(id, 0)
+	require.NoError(t, err)
+	job.Write("one")
+	job.Write("two")
+
+	// Read only the first line then disconnect
+	buf := make([]byte, 4)
+	_, err = io.ReadFull(r, buf)
+	require.NoError(t, err)
+	assert.Equal(t, "one\n", string(buf))
+	require.NoError(t, r.Close())
+	testutil.UntilPass(t, 10, time.Millisecond*100, func(t testutil.TestingT) {
+		assert.Equal(t, 4, offset())
+	})
+
+	job.Write("three")
+	close(job.lines)
+	<-job.done
+
+	// Reconnect and resume where the previous reader left off
+	r, offset, err = runner.NewResumableReader(id, offset())
+	require.NoError(t, err)
+	require.NoError(t, runner.Stop(ctx, id))
+	out, err := io.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, "two\nthree\n", string(out))
+	assert.Equal(t, len("one\ntwo\nthree\n"), offset())
+
+	// Resume from the stopped job
+	r, offset, err = runner.NewResumableReader(id, 8)
+	require.NoError(t, err)
+	out, err = io.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, "three\n", string(out))
+	assert.Equal(t, len("one\ntwo\nthree\n"), offset())
+}
+
+func TestNewJobRunnerBounded(t *testing.T) {
+	runner := steve.NewJobRunnerBounded(2)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	first, err := runner.Run(ctx, newWriterJob())
+	require.NoError(t, err)
+	_, err = runner.Run(ctx, newWriterJob())
+	require.NoError(t, err)
+
+	// Jobs are not evicted once the runner is full
+	_, err = runner.Run(ctx, newWriterJob())
+	assert.ErrorIs(t, err, steve.ErrCapacityExceeded)
+	assert.Len(t, runner.List(), 2)
+
+	// Running jobs can not be removed
+	assert.ErrorIs(t, runner.Remove(first), steve.ErrJobRunning)
+	assert.ErrorIs(t, runner.Remove("unknown"), steve.ErrJobNotFound)
+
+	require.NoError(t, runner.Stop(ctx, first))
+	testutil.UntilPass(t, 10, time.Millisecond*100, func(t testutil.TestingT) {
+		assert.NoError(t, runner.Remove(first))
+	})
+	_, ok := runner.Status(first)
+	assert.False(t, ok)
+
+	// Removing a job frees a slot
+	_, err = runner.Run(ctx, newWriterJob())
+	require.NoError(t, err)
+}
+
+func TestFlush(t *testing.T) {
+	runner := steve.NewJobRunner(20, steve.WithBroadcastInterval(time.Hour))
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	events, unsubscribe := runner.Events()
+	defer unsubscribe()
+
+	job := newWriterJob()
+	id, err := runner.Run(ctx, job)
+	require.NoError(t, err)
+
+	r, err := runner.NewReader(id)
+	require.NoError(t, err)
+	defer r.Close()
+
+	// Wait until the runner has collected the output
+	job.Write("one")
+	for e := range events {
+		if e.Type == steve.EventOutput {
+			break
+		}
+	}
+
+	require.NoError(t, runner.Flush(id))
+	lines := make(chan string)
+	go func() {
+		line, _ := bufio.NewReader(r).ReadString('\n')
+		lines <- line
+	}()
+	select {
+	case line := <-lines:
+		assert.Equal(t, "one\n", line)
+	case <-time.After(time.Second):
+		t.Fatal("reader did not receive output after Flush")
+	}
+
+	require.NoError(t, runner.Stop(ctx, id))
+	assert.ErrorIs(t, runner.Flush("unknown"), steve.ErrJobNotFound)
+}
+
+func TestStatusDuration(t *testing.T) {
+	started := time.Now().Add(-time.Minute)
+
+	// A running job has been running since it started
+	s := steve.Status{Running: true, Started: started}
+	assert.GreaterOrEqual(t, s.Duration(), time.Minute)
+
+	// A stopped job ran until it stopped
+	s = steve.Status{Started: started, Stopped: started.Add(time.Second * 5)}
+	assert.Equal(t, time.Second*5, s.Duration())
+}
+
+func TestRunCancelable(t *testing.T) {
+	runner := steve.NewJobRunner(20)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	id, stop, err := runner.RunCancelable(ctx, newWriterJob())
+	require.NoError(t, err)
+
+	s, ok := runner.Status(id)
+	require.True(t, ok)
+	assert.True(t, s.Running)
+
+	stop()
+	testutil.UntilPass(t, 10, time.Millisecond*100, func(t testutil.TestingT) {
+		s, ok := runner.Status(id)
+		assert.True(t, ok)
+		assert.False(t, s.Running)
+	})
+	// Calling stop again has no effect
+	stop()
+
+	// Calling stop after the job completed has no effect
+	id, stop, err = runner.RunCancelable(ctx, steve.NewExecJob("true"))
+	require.NoError(t, err)
+	testutil.UntilPass(t, 10, time.Millisecond*100, func(t testutil.TestingT) {
+		s, ok := runner.Status(id)
+		assert.True(t, ok)
+		assert.False(t, s.Running)
+	})
+	stop()
+}
+
+func TestNewReaderLimited(t *testing.T) {
+	runner := steve.NewJobRunner(20)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	id, err := runner.Run(ctx, &linesJob{count: 1_000})
+	require.NoError(t, err)
+	defer func() { _ = runner.Stop(ctx, id) }()
+
+	r, err := runner.NewReaderLimited(id, 1_000)
+	require.NoError(t, err)
+
+	var received int64
+	go func() {
+		buf := make([]byte, 100)
+		for {
+			n, err := r.Read(buf)
+			atomic.AddInt64(&received, int64(n))
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	// Other readers are not limited
+	unlimited, err := runner.NewReader(id)
+	require.NoError(t, err)
+	buf := make([]byte, 1_000*len("line: 999\n")/2)
+	_, err = io.ReadFull(unlimited, buf)
+	require.NoError(t, err)
+	require.NoError(t, unlimited.Close())
+
+	time.Sleep(time.Second)
+	require.NoError(t, r.Close())
+
+	// One second at the limit, plus the initial burst and some tolerance
+	n := atomic.LoadInt64(&received)
+	assert.Greater(t, n, int64(500))
+	assert.LessOrEqual(t, n, int64(1_300))
+
+	s, ok := runner.Status(id)
+	require.True(t, ok)
+	assert.True(t, s.Running)
+}
+
+func TestRunTransform(t *testing.T) {
+	runner := steve.NewJobRunner(20)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	ansi := regexp.MustCompile("\x1b\\[[0-9;]*m")
+	job := newWriterJob()
+	id, err := runner.RunWithOptions(ctx, job, steve.RunOptions{
+		Transform: func(b []byte) []byte {
+			return ansi.ReplaceAll(b, nil)
+		},
+	})
+	require.NoError(t, err)
+
+	job.Write("\x1b[31mred\x1b[0m")
+	job.Write("\x1b[1;32mbold green\x1b[0m")
+	close(job.lines)
+	<-job.done
+	require.NoError(t, runner.Stop(ctx, id))
+
+	r, err := runner.NewReader(id)
+	require.NoError(t, err)
+	out, err := io.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, "red\nbold green\n", string(out))
+}
+
+func TestSnapshot(t *testing.T) {
+	runner := steve.NewJobRunner(20)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	events, unsubscribe := runner.Events()
+	defer unsubscribe()
+
+	job := newWriterJob()
+	id, err := runner.Run(ctx, job)
+	require.NoError(t, err)
+
+	// Wait until the runner has collected the output
+	job.Write("one")
+	for e := range events {
+		if e.Type == steve.EventOutput {
+			break
+		}
+	}
+
+	data, offset, err := runner.Snapshot(id)
+	require.NoError(t, err)
+	assert.Equal(t, "one\n", string(data))
+	assert.Equal(t, 4, offset)
+
+	// The snapshot is unaffected by output written after it was taken
+	job.Write("two")
+	close(job.lines)
+	<-job.done
+	require.NoError(t, runner.Stop(ctx, id))
+	assert.Equal(t, "one\n", string(data))
+
+	// The offset resumes reading after the snapshot
+	r, _, err := runner.NewResumableReader(id, offset)
+	require.NoError(t, err)
+	out, err := io.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, "two\n", string(out))
+
+	_, _, err = runner.Snapshot("unknown")
+	assert.ErrorIs(t, err, steve.ErrJobNotFound)
+}
+
+func TestNewJobRunnerMemBudget(t *testing.T) {
+	const budget = 20_000
+	runner := steve.NewJobRunnerMemBudget(budget)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	retained := func() int {
+		var total int
+		for _, s := range runner.List() {
+			total += s.Retained
+		}
+		return total
+	}
+
+	// Each job writes just under 10KB of output
+	var stopped []steve.ID
+	for i := 0; i < 4; i++ {
+		id, err := runner.Run(ctx, &linesJob{count: 1_000})
+		require.NoError(t, err)
+		require.NoError(t, runner.Stop(ctx, id))
+		testutil.UntilPass(t, 10, time.Millisecond*100, func(t testutil.TestingT) {
+			s, ok := runner.Status(id)
+			assert.True(t, ok)
+			assert.False(t, s.Running)
+		})
+		stopped = append(stopped, id)
+		assert.LessOrEqual(t, retained(), budget)
+	}
+
+	// The oldest stopped jobs were evicted to stay within the budget
+	_, ok := runner.Status(stopped[0])
+	assert.False(t, ok)
+	s, ok := runner.Status(stopped[3])
+	require.True(t, ok)
+	assert.Equal(t, 9890, s.Retained)
+
+	// Running jobs are truncated once there are no stopped jobs left to evict
+	var running []steve.ID
+	for i := 0; i < 3; i++ {
+		id, err := runner.Run(ctx, &linesJob{count: 1_000})
+		require.NoError(t, err)
+		running = append(running, id)
+	}
+	// Wait until all the output of the last job has been collected
+	testutil.UntilPass(t, 10, time.Millisecond*100, func(t testutil.TestingT) {
+		data, _, err := runner.Snapshot(running[2])
+		assert.NoError(t, err)
+		assert.True(t, bytes.HasSuffix(data, []byte("line: 999\n")))
+	})
+	for _, id := range stopped {
+		_, ok := runner.Status(id)
+		assert.False(t, ok)
+	}
+	assert.LessOrEqual(t, retained(), budget)
+
+	// The newest output of the oldest running job is retained
+	data, _, err := runner.Snapshot(running[0])
+	require.NoError(t, err)
+	assert.True(t, bytes.HasSuffix(data, []byte("line: 999\n")))
+	for _, id := range running {
+		require.NoError(t, runner.Stop(ctx, id))
+	}
+}
+
+func TestWithInstanceDetection(t *testing.T) {
+	runner := steve.NewJobRunner(20, steve.WithInstanceDetection())
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	job := newWriterJob()
+	id, err := runner.Run(ctx, job)
+	require.NoError(t, err)
+
+	// Running the same instance again returns the existing job
+	existing, err := runner.Run(ctx, job)
+	assert.ErrorIs(t, err, steve.ErrAlreadyRunning)
+	assert.Equal(t, id, existing)
+	assert.Len(t, runner.List(), 1)
+
+	// Other instances are unaffected
+	other, err := runner.Run(ctx, newWriterJob())
+	require.NoError(t, err)
+	assert.NotEqual(t, id, other)
+
+	// The instance can be run again once stopped
+	require.NoError(t, runner.Stop(ctx, id))
+	testutil.UntilPass(t, 10, time.Millisecond*100, func(t testutil.TestingT) {
+		s, ok := runner.Status(id)
+		assert.True(t, ok)
+		assert.False(t, s.Running)
+	})
+	again, err := runner.Run(ctx, job)
+	require.NoError(t, err)
+	assert.NotEqual(t, id, again)
+
+	// A job holding an uncomparable value can not be detected and is always run
+	value := valueJob{data: []string{"uncomparable"}}
+	first, err := runner.Run(ctx, value)
+	require.NoError(t, err)
+	second, err := runner.Run(ctx, value)
+	require.NoError(t, err)
+	assert.NotEqual(t, first, second)
+	for _, id := range []steve.ID{first, second} {
+		require.NoError(t, runner.Stop(ctx, id))
+	}
+}
+
+// valueJob is a comparable type which may hold an uncomparable value
+type valueJob struct {
+	data any
+}
+
+func (v valueJob) Start(ctx context.Context, writer io.Writer) error {
+	return nil
+}
+
+func (v valueJob) Stop(ctx context.Context) error {
+	return nil
+}
+
+func TestRunCollapseRepeats(t *testing.T) {
+	runner := steve.NewJobRunner(20)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	job := newWriterJob()
+	id, err := runner.RunWithOptions(ctx, job, steve.RunOptions{CollapseRepeats: true})
+	require.NoError(t, err)
+
+	job.Write("starting")
+	for i := 0; i < 42; i++ {
+		job.Write("retrying...")
+	}
+	job.Write("connected")
+	job.Write("done")
+	job.Write("done")
+	close(job.lines)
+	<-job.done
+	require.NoError(t, runner.Stop(ctx, id))
+
+	r, err := runner.NewReader(id)
+	require.NoError(t, err)
+	out, err := io.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, "starting\nretrying... (x42)\nconnected\ndone (x2)\n", string(out))
+}
+
+func TestNoGoroutineLeaks(t *testing.T) {
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+
+	runner := steve.NewJobRunner(200)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	// Rapidly start and stop many jobs, stopping some while the monitor
+	// is blocked reading from the job and some after the job has written.
+	var ids []steve.ID
+	for i := 0; i < 100; i++ {
+		id, err := runner.Run(ctx, &linesJob{count: i})
+		require.NoError(t, err)
+		require.NoError(t, runner.Stop(ctx, id))
+		ids = append(ids, id)
+	}
+
+	// Both the monitor go routine and its inner read go routine
+	// must exit once the job is no longer running.
+	testutil.UntilPass(t, 10, time.Millisecond*100, func(t testutil.TestingT) {
+		for _, id := range ids {
+			s, ok := runner.Status(id)
+			assert.True(t, ok)
+			assert.False(t, s.Running)
+		}
+	})
+}
+
+func TestMark(t *testing.T) {
+	runner := steve.NewJobRunner(20)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	events, unsubscribe := runner.Events()
+	defer unsubscribe()
+
+	job := newWriterJob()
+	id, err := runner.Run(ctx, job)
+	require.NoError(t, err)
+
+	// write the line and wait until the runner has collected it
+	write := func(line string) {
+		job.Write(line)
+		for e := range events {
+			if e.Type == steve.EventOutput {
+				break
+			}
+		}
+	}
+
+	write("phase: build")
+	build, err := runner.Mark(id, "build done")
+	require.NoError(t, err)
+	write("phase: test")
+	test, err := runner.Mark(id, "test done")
+	require.NoError(t, err)
+	write("finished")
+	require.NoError(t, runner.Stop(ctx, id))
+
+	markers, err := runner.Markers(id)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"build done": build, "test done": test}, markers)
+
+	// The markers are the positions in the stream where each mark was made
+	data, _, err := runner.Snapshot(id)
+	require.NoError(t, err)
+	assert.Equal(t, "phase: build\n", string(data[:build]))
+	assert.Equal(t, "phase: test\n", string(data[build:test]))
+	assert.Equal(t, "finished\n", string(data[test:]))
+
+	_, err = runner.Mark("unknown", "marker")
+	assert.ErrorIs(t, err, steve.ErrJobNotFound)
+}
+
+// slowStopJob blocks in Stop until released
+type slowStopJob struct {
+	stopping chan struct{}
+	release  chan struct{}
+}
+
+func (s *slowStopJob) Start(ctx context.Context, writer io.Writer) error {
+	return nil
+}
+
+func (s *slowStopJob) Stop(ctx context.Context) error {
+	close(s.stopping)
+	<-s.release
+	return nil
+}
+
+func TestTryStop(t *testing.T) {
+	runner := steve.NewJobRunner(20)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	slow := &slowStopJob{stopping: make(chan struct{}), release: make(chan struct{})}
+	slowID, err := runner.Run(ctx, slow)
+	require.NoError(t, err)
+	id, err := runner.Run(ctx, newWriterJob())
+	require.NoError(t, err)
+
+	stopped := make(chan error)
+	go func() {
+		stopped <- runner.Stop(ctx, slowID)
+	}()
+	<-slow.stopping
+
+	// While the slow Stop is in progress TryStop does not wait
+	assert.ErrorIs(t, runner.TryStop(id), steve.ErrWouldBlock)
+
+	close(slow.release)
+	require.NoError(t, <-stopped)
+	require.NoError(t, runner.TryStop(id))
+	assert.ErrorIs(t, runner.TryStop("unknown"), steve.ErrJobNotFound)
+
+	// TryStop does not wait on a job which is slow to stop, the job stops in the background
+	slow = &slowStopJob{stopping: make(chan struct{}), release: make(chan struct{})}
+	slowID, err = runner.Run(ctx, slow)
+	require.NoError(t, err)
+	start := time.Now()
+	assert.ErrorIs(t, runner.TryStop(slowID), steve.ErrWouldBlock)
+	assert.Less(t, time.Since(start), time.Millisecond*500)
+	<-slow.stopping
+
+	close(slow.release)
+	testutil.UntilPass(t, 10, time.Millisecond*100, func(t testutil.TestingT) {
+		s, ok := runner.Status(slowID)
+		assert.True(t, ok)
+		assert.False(t, s.Running)
+	})
+}
+
+func TestStopGracefulDoesNotHoldRunner(t *testing.T) {
+	runner := steve.NewJobRunner(20)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	slow := &slowStopJob{stopping: make(chan struct{}), release: make(chan struct{})}
+	slowID, err := runner.Run(ctx, slow)
+	require.NoError(t, err)
+	id, err := runner.Run(ctx, newWriterJob())
+	require.NoError(t, err)
+
+	stopped := make(chan error)
+	go func() {
+		stopped <- runner.StopGraceful(ctx, slowID, time.Second)
+	}()
+	<-slow.stopping
+
+	// Other jobs can be stopped while waiting on the grace period
+	require.NoError(t, runner.TryStop(id))
+
+	close(slow.release)
+	require.NoError(t, <-stopped)
+}
+
+func TestWithIDGenerator(t *testing.T) {
+	var count int
+	runner := steve.NewJobRunner(20, steve.WithIDGenerator(func() steve.ID {
+		count++
+		return steve.ID(fmt.Sprintf("build-%d", count))
+	}))
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	for i := 1; i <= 3; i++ {
+		id, err := runner.Run(ctx, &linesJob{count: 1})
+		require.NoError(t, err)
+		assert.Equal(t, steve.ID(fmt.Sprintf("build-%d", i)), id)
+	}
+
+	// A generated ID which collides with an existing job
+	require.NoError(t, runner.RunWithID(ctx, "build-4", &linesJob{count: 1}))
+	_, err := runner.Run(ctx, &linesJob{count: 1})
+	assert.ErrorIs(t, err, steve.ErrJobExists)
+}
+
+func TestStatusFirstOutput(t *testing.T) {
+	runner := steve.NewJobRunner(20)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	job := newWriterJob()
+	id, err := runner.Run(ctx, job)
+	require.NoError(t, err)
+	defer func() { _ = runner.Stop(ctx, id) }()
+
+	s, ok := runner.Status(id)
+	require.True(t, ok)
+	assert.True(t, s.FirstOutput.IsZero())
+
+	time.Sleep(time.Millisecond * 100)
+	job.Write("hello")
+	testutil.UntilPass(t, 10, time.Millisecond*100, func(t testutil.TestingT) {
+		s, ok := runner.Status(id)
+		assert.True(t, ok)
+		assert.GreaterOrEqual(t, s.FirstOutput.Sub(s.Started), time.Millisecond*100)
+	})
+
+	// Only the first output is recorded
+	s, _ = runner.Status(id)
+	job.Write("world")
+	time.Sleep(time.Millisecond * 50)
+	after, _ := runner.Status(id)
+	assert.Equal(t, s.FirstOutput, after.FirstOutput)
+}
+
+func TestStatusMany(t *testing.T) {
+	runner := steve.NewJobRunner(20)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	first, err := runner.Run(ctx, newWriterJob())
+	require.NoError(t, err)
+	second, err := runner.Run(ctx, newWriterJob())
+	require.NoError(t, err)
+	require.NoError(t, runner.Stop(ctx, second))
+
+	statuses := runner.StatusMany([]steve.ID{first, "unknown", second})
+	assert.Len(t, statuses, 2)
+	assert.Equal(t, first, statuses[first].ID)
+	assert.True(t, statuses[first].Running)
+	assert.Equal(t, second, statuses[second].ID)
+	assert.NotContains(t, statuses, steve.ID("unknown"))
+
+	assert.Empty(t, runner.StatusMany(nil))
+	require.NoError(t, runner.Stop(ctx, first))
+}
+
+func TestWithOutputLogger(t *testing.T) {
+	var mutex sync.Mutex
+	var logged []string
+	runner := steve.NewJobRunner(20, steve.WithOutputLogger(func(id steve.ID, line []byte) {
+		mutex.Lock()
+		logged = append(logged, string(id)+": "+string(line))
+		mutex.Unlock()
+	}))
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	// Lines split across writes, ending with a partial line
+	err := runner.RunWithID(ctx, "job", &chunksJob{chunks: []string{"one\ntw", "o\nthree\n", "four"}})
+	require.NoError(t, err)
+	require.NoError(t, runner.Stop(ctx, "job"))
+
+	testutil.UntilPass(t, 10, time.Millisecond*100, func(t testutil.TestingT) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		assert.Equal(t, []string{"job: one\n", "job: two\n", "job: three\n", "job: four"}, logged)
+	})
+}
+
+func TestRunPriority(t *testing.T) {
+	runner := steve.NewJobRunner(2)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	high, err := runner.RunWithOptions(ctx, &linesJob{count: 1}, steve.RunOptions{Priority: 10})
+	require.NoError(t, err)
+	low, err := runner.RunWithOptions(ctx, &linesJob{count: 1}, steve.RunOptions{Priority: 1})
+	require.NoError(t, err)
+
+	// The low priority job is evicted even though the high priority job is older
+	next, err := runner.Run(ctx, &linesJob{count: 1})
+	require.NoError(t, err)
+	_, ok := runner.Status(low)
+	assert.False(t, ok)
+	_, ok = runner.Status(high)
+	assert.True(t, ok)
+
+	// Among jobs of the same priority, stopped jobs are evicted first
+	other, err := runner.RunWithOptions(ctx, &linesJob{count: 1}, steve.RunOptions{Priority: 10})
+	require.NoError(t, err)
+	_, ok = runner.Status(next)
+	assert.False(t, ok)
+
+	require.NoError(t, runner.Stop(ctx, other))
+	testutil.UntilPass(t, 10, time.Millisecond*100, func(t testutil.TestingT) {
+		s, ok := runner.Status(other)
+		assert.True(t, ok)
+		assert.False(t, s.Running)
+	})
+	_, err = runner.RunWithOptions(ctx, &linesJob{count: 1}, steve.RunOptions{Priority: 10})
+	require.NoError(t, err)
+	_, ok = runner.Status(other)
+	assert.False(t, ok)
+	_, ok = runner.Status(high)
+	assert.True(t, ok)
+}
+
+func TestRunEvictLeastRecentlyUsed(t *testing.T) {
+	runner := steve.NewJobRunner(2)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	defer func() { require.NoError(t, runner.Close(ctx)) }()
+
+	older, err := runner.Run(ctx, &linesJob{count: 1})
+	require.NoError(t, err)
+	newer, err := runner.Run(ctx, &linesJob{count: 1})
+	require.NoError(t, err)
+
+	// Reading the output of the older job marks it as recently used
+	_, _, err = runner.Snapshot(older)
+	require.NoError(t, err)
+
+	// Among jobs of the same priority, the least recently used job is evicted
+	_, err = runner.Run(ctx, &linesJob{count: 1})
+	require.NoError(t, err)
+	_, ok := runner.Status(older)
+	assert.True(t, ok)
+	_, ok = runner.Status(newer)
+	assert.False(t, ok)
+}
+
+func TestRunRetainAfterStop(t *testing.T) {
+	runner := steve.NewJobRunner(20, steve.WithSweepInterval(time.Millisecond*10))
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	defer func() { require.NoError(t, runner.Close(ctx)) }()
+
+	id, err := runner.RunWithOptions(ctx, &linesJob{count: 1}, steve.RunOptions{
+		RetainAfterStop: time.Millisecond * 100,
+	})
+	require.NoError(t, err)
+	retained, err := runner.Run(ctx, &linesJob{count: 1})
+	require.NoError(t, err)
+
+	require.NoError(t, runner.Stop(ctx, id))
+	require.NoError(t, runner.Stop(ctx, retained))
+
+	// The job is retained until the TTL elapses
+	_, ok := runner.Status(id)
+	assert.True(t, ok)
+
+	testutil.UntilPass(t, 20, time.Millisecond*50, func(t testutil.TestingT) {
+		for _, s := range runner.List() {
+			assert.NotEqual(t, id, s.ID)
+		}
+	})
+
+	// Jobs without a TTL are retained
+	_, ok = runner.Status(retained)
+	assert.True(t, ok)
+}
+
+func TestRunIdleTimeout(t *testing.T) {
+	clock := newFakeClock()
+	runner := steve.NewJobRunner(20, steve.WithClock(clock))
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	defer func() { require.NoError(t, runner.Close(ctx)) }()
+
+	opts := steve.RunOptions{IdleTimeout: time.Minute}
+
+	t.Run("Burst", func(t *testing.T) {
+		job := newWriterJob()
+		id, err := runner.RunWithOptions(ctx, job, opts)
+		require.NoError(t, err)
+
+		// Each write resets the idle window
+		for i, line := range []string{"one", "two", "three"} {
+			clock.Advance(time.Second * 30)
+			job.Write(line)
+			testutil.UntilPass(t, 10, time.Millisecond*100, func(t testutil.TestingT) {
+				n, err := runner.BufferLen(id)
+				assert.NoError(t, err)
+				assert.Equal(t, []int{4, 8, 14}[i], n)
+			})
+		}
+
+		clock.Advance(time.Second * 30)
+		s, ok := runner.Status(id)
+		require.True(t, ok)
+		assert.True(t, s.Running)
+
+		// Once silent for the IdleTimeout the job is stopped
+		testutil.UntilPass(t, 10, time.Millisecond*100, func(t testutil.TestingT) {
+			clock.Advance(time.Minute)
+			s, ok := runner.Status(id)
+			assert.True(t, ok)
+			assert.False(t, s.Running)
+		})
+		close(job.lines)
+	})
+
+	t.Run("Chatty", func(t *testing.T) {
+		job := newWriterJob()
+		id, err := runner.RunWithOptions(ctx, job, opts)
+		require.NoError(t, err)
+
+		// Writes extend the idle window without creating a timer for each write
+		for i := 0; i < 100; i++ {
+			job.Write("line")
+		}
+		testutil.UntilPass(t, 10, time.Millisecond*100, func(t testutil.TestingT) {
+			n, err := runner.BufferLen(id)
+			assert.NoError(t, err)
+			assert.Equal(t, 500, n)
+		})
+		assert.Equal(t, 1, clock.Waiting())
+
+		close(job.lines)
+		<-job.done
+		require.NoError(t, runner.Stop(ctx, id))
+	})
+
+	t.Run("NoOutput", func(t *testing.T) {
+		job := newWriterJob()
+		defer close(job.lines)
+		id, err := runner.RunWithOptions(ctx, job, opts)
+		require.NoError(t, err)
+
+		testutil.UntilPass(t, 10, time.Millisecond*100, func(t testutil.TestingT) {
+			clock.Advance(time.Minute)
+			s, ok := runner.Status(id)
+			assert.True(t, ok)
+			assert.False(t, s.Running)
+		})
+	})
+}
+
+func TestRunFreeOnStopIfUnread(t *testing.T) {
+	runner := steve.NewJobRunner(20)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	opts := steve.RunOptions{FreeOnStopIfUnread: true}
+	unread, err := runner.RunWithOptions(ctx, &linesJob{count: 100}, opts)
+	require.NoError(t, err)
+	read, err := runner.RunWithOptions(ctx, &linesJob{count: 100}, opts)
+	require.NoError(t, err)
+	r, err := runner.NewReader(read)
+	require.NoError(t, err)
+
+	for _, id := range []steve.ID{unread, read} {
+		require.NoError(t, runner.Stop(ctx, id))
+		testutil.UntilPass(t, 10, time.Millisecond*100, func(t testutil.TestingT) {
+			s, ok := runner.Status(id)
+			assert.True(t, ok)
+			assert.False(t, s.Running)
+		})
+	}
+
+	// The output of the unread job is freed
+	n, err := runner.BufferLen(unread)
+	require.NoError(t, err)
+	assert.Equal(t, 0, n)
+	_, err = runner.NewReader(unread)
+	assert.ErrorIs(t, err, steve.ErrOutputDiscarded)
+	_, _, err = runner.Snapshot(unread)
+	assert.ErrorIs(t, err, steve.ErrOutputDiscarded)
+
+	// The output of a job which was read is retained
+	out, err := io.ReadAll(r)
+	require.NoError(t, err)
+	assert.Contains(t, string(out), "line: 99\n")
+	data, _, err := runner.Snapshot(read)
+	require.NoError(t, err)
+	assert.Equal(t, string(out), string(data))
+}
+
+func TestOutputHash(t *testing.T) {
+	runner := steve.NewJobRunner(20)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	// The ring retains only a fraction of the output, the hash covers all of it
+	id, err := runner.RunWithOptions(ctx, &linesJob{count: 1_000}, steve.RunOptions{
+		Retention: steve.RetainBytes(100),
+	})
+	require.NoError(t, err)
+	require.NoError(t, runner.Stop(ctx, id))
+	testutil.UntilPass(t, 10, time.Millisecond*100, func(t testutil.TestingT) {
+		s, ok := runner.Status(id)
+		assert.True(t, ok)
+		assert.False(t, s.Running)
+	})
+
+	var expected bytes.Buffer
+	for i := 0; i < 1_000; i++ {
+		_, _ = fmt.Fprintf(&expected, "line: %d\n", i)
+	}
+	sum := sha256.Sum256(expected.Bytes())
+
+	hash, err := runner.OutputHash(id)
+	require.NoError(t, err)
+	assert.Equal(t, sum[:], hash)
+	n, err := runner.BufferLen(id)
+	require.NoError(t, err)
+	assert.Equal(t, 100, n)
+
+	_, err = runner.OutputHash("unknown")
+	assert.ErrorIs(t, err, steve.ErrJobNotFound)
+}
+
+func TestClose(t *testing.T) {
+	runner := steve.NewJobRunner(20)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	var ids []steve.ID
+	for i := 0; i < 3; i++ {
+		id, err := runner.Run(ctx, newWriterJob())
+		require.NoError(t, err)
+		ids = append(ids, id)
+	}
+
+	require.NoError(t, runner.Close(ctx))
+	testutil.UntilPass(t, 10, time.Millisecond*100, func(t testutil.TestingT) {
+		for _, id := range ids {
+			s, ok := runner.Status(id)
+			assert.True(t, ok)
+			assert.False(t, s.Running)
+		}
+	})
+}
+
+func TestCloseWaitsForReaders(t *testing.T) {
+	for i := 0; i < 20; i++ {
+		runner := steve.NewJobRunner(20)
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+
+		var ids []steve.ID
+		for n := 0; n < 5; n++ {
+			id, err := runner.Run(ctx, &linesJob{count: 100})
+			require.NoError(t, err)
+			ids = append(ids, id)
+		}
+
+		// Interleave the creation of readers with Close
+		var wg sync.WaitGroup
+		var delivered int64
+		for n := 0; n < 20; n++ {
+			wg.Add(1)
+			go func(id steve.ID) {
+				defer wg.Done()
+				r, err := runner.NewReader(id)
+				if err != nil {
+					assert.ErrorIs(t, err, steve.ErrRunnerClosed)
+					return
+				}
+				out, err := io.ReadAll(r)
+				assert.NoError(t, err)
+				atomic.AddInt64(&delivered, int64(len(out)))
+			}(ids[n%len(ids)])
+		}
+		require.NoError(t, runner.Close(ctx))
+
+		// Every reader registered before Close returned has finished
+		for _, id := range ids {
+			count, err := runner.ReaderCount(id)
+			require.NoError(t, err)
+			assert.Equal(t, 0, count)
+		}
+		wg.Wait()
+
+		_, err := runner.NewReader(ids[0])
+		assert.ErrorIs(t, err, steve.ErrRunnerClosed)
+		cancel()
+	}
+}
+
+func TestBufferLen(t *testing.T) {
+	runner := steve.NewJobRunner(20)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	events, unsubscribe := runner.Events()
+	defer unsubscribe()
+
+	job := newWriterJob()
+	id, err := runner.Run(ctx, job)
+	require.NoError(t, err)
+
+	n, err := runner.BufferLen(id)
+	require.NoError(t, err)
+	assert.Equal(t, 0, n)
+
+	for i, line := range []string{"one", "two", "three"} {
+		job.Write(line)
+		for e := range events {
+			if e.Type == steve.EventOutput {
+				break
+			}
+		}
+		n, err := runner.BufferLen(id)
+		require.NoError(t, err)
+		assert.Equal(t, []int{4, 8, 14}[i], n)
+	}
+	require.NoError(t, runner.Stop(ctx, id))
+
+	_, err = runner.BufferLen("unknown")
+	assert.ErrorIs(t, err, steve.ErrJobNotFound)
+}
+
+func TestTotalBufferedBytes(t *testing.T) {
+	runner := steve.NewJobRunner(20)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	assert.Equal(t, 0, runner.TotalBufferedBytes())
+
+	var ids []steve.ID
+	for _, count := range []int{10, 100, 1_000} {
+		id, err := runner.Run(ctx, &linesJob{count: count})
+		require.NoError(t, err)
+		require.NoError(t, runner.Stop(ctx, id))
+		testutil.UntilPass(t, 10, time.Millisecond*100, func(t testutil.TestingT) {
+			s, ok := runner.Status(id)
+			assert.True(t, ok)
+			assert.False(t, s.Running)
+		})
+		ids = append(ids, id)
+	}
+
+	var expected int
+	for _, id := range ids {
+		n, err := runner.BufferLen(id)
+		require.NoError(t, err)
+		assert.NotZero(t, n)
+		expected += n
+	}
+	assert.Equal(t, expected, runner.TotalBufferedBytes())
+
+	require.NoError(t, runner.Remove(ids[0]))
+	n, err := runner.BufferLen(ids[1])
+	require.NoError(t, err)
+	m, err := runner.BufferLen(ids[2])
+	require.NoError(t, err)
+	assert.Equal(t, n+m, runner.TotalBufferedBytes())
+}
+
+func TestCacheStats(t *testing.T) {
+	runner := steve.NewJobRunner(2)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	id, err := runner.Run(ctx, &linesJob{count: 1})
+	require.NoError(t, err)
+
+	hits, misses, evictions := runner.CacheStats()
+	assert.Equal(t, []int{0, 0, 0}, []int{hits, misses, evictions})
+
+	// Known IDs are hits
+	_, ok := runner.Status(id)
+	assert.True(t, ok)
+	r, err := runner.NewReader(id)
+	require.NoError(t, err)
+	require.NoError(t, r.Close())
+	require.NoError(t, runner.Stop(ctx, id))
+	hits, misses, _ = runner.CacheStats()
+	assert.Equal(t, 3, hits)
+	assert.Equal(t, 0, misses)
+
+	// Unknown IDs are misses
+	_, ok = runner.Status("unknown")
+	assert.False(t, ok)
+	_, err = runner.NewReader("unknown")
+	assert.ErrorIs(t, err, steve.ErrJobNotFound)
+	assert.ErrorIs(t, runner.Stop(ctx, "unknown"), steve.ErrJobNotFound)
+	hits, misses, _ = runner.CacheStats()
+	assert.Equal(t, 3, hits)
+	assert.Equal(t, 3, misses)
+
+	// Exceeding the capacity evicts the stopped job
+	testutil.UntilPass(t, 10, time.Millisecond*100, func(t testutil.TestingT) {
+		s, ok := runner.Status(id)
+		assert.True(t, ok)
+		assert.False(t, s.Running)
+	})
+	for i := 0; i < 2; i++ {
+		other, err := runner.Run(ctx, &linesJob{count: 1})
+		require.NoError(t, err)
+		defer func() { require.NoError(t, runner.Stop(ctx, other)) }()
+	}
+	_, _, evictions = runner.CacheStats()
+	assert.Equal(t, 1, evictions)
+}
+
+func TestReaderHandleReason(t *testing.T) {
+	clock := newFakeClock()
+	runner := steve.NewJobRunner(20, steve.WithClock(clock))
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	// read all the output of the job, returning the reason the output ended
+	read := func(t *testing.T, r *steve.ReaderHandle) steve.EndReason {
+		_, err := io.ReadAll(r)
+		require.NoError(t, err)
+		return r.Reason()
+	}
+
+	t.Run("Completed", func(t *testing.T) {
+		id, err := runner.Run(ctx, &flakyJob{})
+		require.NoError(t, err)
+		r, err := runner.NewReaderHandle(id)
+		require.NoError(t, err)
+		assert.Equal(t, steve.EndCompleted, read(t, r))
+	})
+
+	t.Run("Stopped", func(t *testing.T) {
+		job := newWriterJob()
+		defer close(job.lines)
+		id, err := runner.Run(ctx, job)
+		require.NoError(t, err)
+		r, err := runner.NewReaderHandle(id)
+		require.NoError(t, err)
+		assert.Equal(t, steve.EndNone, r.Reason())
+
+		require.NoError(t, runner.Stop(ctx, id))
+		assert.Equal(t, steve.EndStopped, read(t, r))
+	})
+
+	t.Run("TimedOut", func(t *testing.T) {
+		job := newWriterJob()
+		defer close(job.lines)
+		id, err := runner.RunWithOptions(ctx, job, steve.RunOptions{IdleTimeout: time.Minute})
+		require.NoError(t, err)
+		r, err := runner.NewReaderHandle(id)
+		require.NoError(t, err)
+
+		testutil.UntilPass(t, 10, time.Millisecond*100, func(t testutil.TestingT) {
+			clock.Advance(time.Minute)
+			s, ok := runner.Status(id)
+			assert.True(t, ok)
+			assert.False(t, s.Running)
+		})
+		assert.Equal(t, steve.EndTimedOut, read(t, r))
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		id, err := runner.Run(ctx, &flakyJob{failures: 1})
+		require.NoError(t, err)
+		r, err := runner.NewReaderHandle(id)
+		require.NoError(t, err)
+		assert.Equal(t, steve.EndError, read(t, r))
+	})
+}
+
+func TestCloseReader(t *testing.T) {
+	runner := steve.NewJobRunner(20)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	job := newWriterJob()
+	id, err := runner.Run(ctx, job)
+	require.NoError(t, err)
+
+	first, err := runner.NewReaderHandle(id)
+	require.NoError(t, err)
+	second, err := runner.NewReaderHandle(id)
+	require.NoError(t, err)
+	defer second.Close()
+	assert.NotEqual(t, first.ID, second.ID)
+
+	require.NoError(t, runner.CloseReader(id, first.ID))
+	_, err = first.Read(make([]byte, 10))
+	assert.ErrorIs(t, err, steve.ErrReaderClosed)
+	assert.ErrorIs(t, runner.CloseReader(id, "unknown"), steve.ErrReaderNotFound)
+
+	// The other reader keeps receiving output
+	job.Write("hello")
+	line, err := bufio.NewReader(second).ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, "hello\n", line)
+
+	testutil.UntilPass(t, 10, time.Millisecond*100, func(t testutil.TestingT) {
+		count, err := runner.ReaderCount(id)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, count)
+	})
+	require.NoError(t, runner.Stop(ctx, id))
+}
+
+// slowStore is an OutputStore which is slow to store output
+type slowStore struct {
+	steve.BytesBufferStore
+	delay time.Duration
+}
+
+func (s *slowStore) Write(b []byte) (int, error) {
+	time.Sleep(s.delay)
+	return s.BytesBufferStore.Write(b)
+}
+
+func TestWithOutputBuffer(t *testing.T) {
+	for _, policy := range []steve.OverflowPolicy{steve.OverflowDropNewest, steve.OverflowDropOldest} {
+		runner := steve.NewJobRunner(20,
+			steve.WithOutputBuffer(10, policy),
+			steve.WithOutputStore(func() steve.OutputStore {
+				return &slowStore{delay: time.Millisecond * 10}
+			}),
+		)
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+
+		// Storing 1000 lines takes at least 10 seconds, but the job is never blocked
+		start := time.Now()
+		id, err := runner.Run(ctx, &linesJob{count: 1_000})
+		require.NoError(t, err)
+		assert.Less(t, time.Since(start), time.Second)
+		require.NoError(t, runner.Stop(ctx, id))
+
+		r, err := runner.NewReader(id)
+		require.NoError(t, err)
+		out, err := io.ReadAll(r)
+		require.NoError(t, err)
+
+		s, ok := runner.Status(id)
+		require.True(t, ok)
+		assert.Greater(t, s.Dropped, 0)
+		assert.Equal(t, len(out)+s.Dropped, 9890)
+		assert.Equal(t, 9890, s.BytesWritten)
+
+		if policy == steve.OverflowDropOldest {
+			// The most recent output is retained
+			assert.True(t, bytes.HasSuffix(out, []byte("line: 999\n")))
+		} else {
+			assert.True(t, bytes.HasPrefix(out, []byte("line: 0\n")))
+		}
+		cancel()
+	}
+
+	// A policy which discards output requires a buffer to discard from
+	assert.Panics(t, func() { steve.WithOutputBuffer(0, steve.OverflowDropNewest) })
+	assert.Panics(t, func() { steve.WithOutputBuffer(0, steve.OverflowDropOldest) })
+	assert.NotPanics(t, func() { steve.WithOutputBuffer(0, steve.OverflowBlock) })
+}
+
+// blockingStore is an OutputStore which blocks storing output until released
+type blockingStore struct {
+	steve.BytesBufferStore
+	writing chan struct{}
+	release chan struct{}
+}
+
+func (s *blockingStore) Write(b []byte) (int, error) {
+	select {
+	case s.writing <- struct{}{}:
+	default:
+	}
+	<-s.release
+	return s.BytesBufferStore.Write(b)
+}
+
+func TestListCtx(t *testing.T) {
+	store := &blockingStore{writing: make(chan struct{}, 1), release: make(chan struct{})}
+	runner := steve.NewJobRunner(20, steve.WithOutputStore(func() steve.OutputStore { return store }))
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	job := newWriterJob()
+	id, err := runner.Run(ctx, job)
+	require.NoError(t, err)
+
+	list, err := runner.ListCtx(ctx)
+	require.NoError(t, err)
+	assert.Len(t, list, 1)
+
+	// Hold the job busy storing output
+	job.Write("line")
+	<-store.writing
+
+	short, shortCancel := context.WithTimeout(ctx, time.Millisecond*50)
+	defer shortCancel()
+	start := time.Now()
+	_, err = runner.ListCtx(short)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	_, err = runner.StatusCtx(short, id)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), time.Millisecond*500)
+
+	// A status waiting on the busy job is returned once the job is no longer busy
+	status := make(chan error)
+	go func() {
+		_, err := runner.StatusCtx(ctx, id)
+		status <- err
+	}()
+	close(store.release)
+	require.NoError(t, <-status)
+
+	// A slow Stop of another job does not block the status of the job
+	slow := &slowStopJob{stopping: make(chan struct{}), release: make(chan struct{})}
+	slowID, err := runner.Run(ctx, slow)
+	require.NoError(t, err)
+	stopped := make(chan error)
+	go func() {
+		stopped <- runner.Stop(ctx, slowID)
+	}()
+	<-slow.stopping
+
+	s, err := runner.StatusCtx(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, id, s.ID)
+	_, err = runner.StatusCtx(ctx, "unknown")
+	assert.ErrorIs(t, err, steve.ErrJobNotFound)
+	list, err = runner.ListCtx(ctx)
+	require.NoError(t, err)
+	assert.Len(t, list, 2)
+
+	close(slow.release)
+	require.NoError(t, <-stopped)
+	close(job.lines)
+	<-job.done
+	require.NoError(t, runner.Stop(ctx, id))
+}
+
+func TestRunAfter(t *testing.T) {
+	runner := steve.NewJobRunner(20)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	a, err := runner.Run(ctx, newWriterJob())
+	require.NoError(t, err)
+
+	job := &blockingJob{started: make(chan struct{})}
+	b, err := runner.RunAfter(ctx, a, job)
+	require.NoError(t, err)
+
+	s, ok := runner.Status(b)
+	require.True(t, ok)
+	assert.True(t, s.Pending)
+	assert.False(t, s.Running)
+	select {
+	case <-job.started:
+		t.Fatal("job started before the after job stopped")
+	case <-time.After(time.Millisecond * 100):
+	}
+
+	require.NoError(t, runner.Stop(ctx, a))
+	select {
+	case <-job.started:
+	case <-ctx.Done():
+		t.Fatal("job did not start once the after job stopped")
+	}
+	testutil.UntilPass(t, 10, time.Millisecond*100, func(t testutil.TestingT) {
+		s, ok := runner.Status(b)
+		assert.True(t, ok)
+		assert.False(t, s.Pending)
+		assert.True(t, s.Running)
+	})
+	require.NoError(t, runner.Stop(ctx, b))
+
+	// Unknown or stopped jobs start immediately
+	for _, after := range []steve.ID{"unknown", a} {
+		id, err := runner.RunAfter(ctx, after, newWriterJob())
+		require.NoError(t, err)
+		s, ok := runner.Status(id)
+		require.True(t, ok)
+		assert.False(t, s.Pending)
+		require.NoError(t, runner.Stop(ctx, id))
+	}
+}
+
+func TestRunNilJob(t *testing.T) {
+	runner := steve.NewJobRunner(20)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	_, err := runner.Run(ctx, nil)
+	assert.ErrorIs(t, err, steve.ErrNilJob)
+	assert.ErrorIs(t, runner.RunWithID(ctx, "nil", nil), steve.ErrNilJob)
+	assert.Empty(t, runner.List())
+}
+
+func TestSearch(t *testing.T) {
+	runner := steve.NewJobRunner(20)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	job := newWriterJob()
+	id, err := runner.Run(ctx, job)
+	require.NoError(t, err)
+
+	for _, line := range []string{"starting", "error: disk full", "retrying", "error: timeout", "error: gave up"} {
+		job.Write(line)
+	}
+	close(job.lines)
+	<-job.done
+	require.NoError(t, runner.Stop(ctx, id))
+
+	testutil.UntilPass(t, 10, time.Millisecond*100, func(t testutil.TestingT) {
+		s, ok := runner.Status(id)
+		assert.True(t, ok)
+		assert.False(t, s.Running)
+	})
+
+	matches, err := runner.Search(id, regexp.MustCompile(`^error: `), 0)
+	require.NoError(t, err)
+	assert.Equal(t, []steve.Match{
+		{Offset: 9, Line: "error: disk full"},
+		{Offset: 35, Line: "error: timeout"},
+		{Offset: 50, Line: "error: gave up"},
+	}, matches)
+
+	// The offset of a match locates the line in the output
+	data, _, err := runner.Snapshot(id)
+	require.NoError(t, err)
+	assert.Equal(t, "error: timeout", string(data[35:49]))
+
+	matches, err = runner.Search(id, regexp.MustCompile(`error`), 2)
+	require.NoError(t, err)
+	require.Len(t, matches, 2)
+	assert.Equal(t, "error: timeout", matches[1].Line)
+
+	matches, err = runner.Search(id, regexp.MustCompile(`panic`), 0)
+	require.NoError(t, err)
+	assert.Empty(t, matches)
+
+	_, err = runner.Search("unknown", regexp.MustCompile(`error`), 0)
+	assert.ErrorIs(t, err, steve.ErrJobNotFound)
+}
+
+func TestRunValidateOnly(t *testing.T) {
+	runner := steve.NewJobRunner(20)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	defer func() { require.NoError(t, runner.Close(ctx)) }()
+
+	// The job is stopped once Start returns, even if the job never writes output, and is not retained
+	id, err := runner.RunWithOptions(ctx, &linesJob{}, steve.RunOptions{ValidateOnly: true})
+	require.NoError(t, err)
+	assert.NotEmpty(t, id)
+	_, ok := runner.Status(id)
+	assert.False(t, ok)
+	assert.Empty(t, runner.List())
+
+	// The job is stopped once ReadyWhen returns true
+	_, err = runner.RunWithOptions(ctx, &testJob{}, steve.RunOptions{
+		ValidateOnly: true,
+		ReadyWhen:    func(line []byte) bool { return bytes.HasPrefix(line, []byte("line: 1")) },
+	})
+	require.NoError(t, err)
+	assert.Empty(t, runner.List())
+
+	// A job which stops without writing a line is not ready
+	_, err = runner.RunWithOptions(ctx, &timedJob{data: []byte("no newline"), duration: time.Millisecond}, steve.RunOptions{
+		ValidateOnly: true,
+		ReadyWhen:    func([]byte) bool { return true },
+	})
+	assert.ErrorIs(t, err, steve.ErrNotReady)
+	assert.Empty(t, runner.List())
+
+	_, err = runner.RunWithOptions(ctx, &failJob{}, steve.RunOptions{ValidateOnly: true})
+	assert.ErrorIs(t, err, errFailedStart)
+	assert.Empty(t, runner.List())
+
+	// A queued job is validated once it starts
+	barrier := make(chan struct{})
+	close(barrier)
+	_, err = runner.RunWithOptions(ctx, &linesJob{}, steve.RunOptions{ValidateOnly: true, Barrier: barrier})
+	require.NoError(t, err)
+	assert.Empty(t, runner.List())
+}
+
+func TestRunReturnsOnceStarted(t *testing.T) {
+	runner := steve.NewJobRunner(20)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	// Start returns immediately, but the job continues to run in the background
+	job := newWriterJob()
+	start := time.Now()
+	id, err := runner.Run(ctx, job)
+	require.NoError(t, err)
+	assert.Less(t, time.Since(start), time.Second)
+
+	s, ok := runner.Status(id)
+	require.True(t, ok)
+	assert.True(t, s.Running)
+
+	r, err := runner.NewReader(id)
+	require.NoError(t, err)
+	job.Write("still running")
+	close(job.lines)
+	<-job.done
+	require.NoError(t, runner.Stop(ctx, id))
+	out, err := io.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, "still running\n", string(out))
+}
+
+// hungJob blocks in Start, ignoring the context, until released
+type hungJob struct {
+	release chan struct{}
+	stopped chan struct{}
+}
+
+func (h *hungJob) Start(ctx context.Context, writer io.Writer) error {
+	<-h.release
+	return nil
+}
+
+func (h *hungJob) Stop(ctx context.Context) error {
+	close(h.stopped)
+	return nil
+}
+
+func TestRunStartTimeout(t *testing.T) {
+	runner := steve.NewJobRunner(20)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	job := &hungJob{release: make(chan struct{}), stopped: make(chan struct{})}
+	start := time.Now()
+	id, err := runner.RunWithOptions(ctx, job, steve.RunOptions{StartTimeout: time.Millisecond * 100})
+	assert.ErrorIs(t, err, steve.ErrStartTimeout)
+	assert.Empty(t, id)
+	assert.GreaterOrEqual(t, time.Since(start), time.Millisecond*100)
+	assert.Less(t, time.Since(start), time.Second)
+
+	// The job is torn down as if it never existed
+	assert.Empty(t, runner.List())
+
+	// Once Start eventually returns the job is stopped
+	close(job.release)
+	select {
+	case <-job.stopped:
+	case <-ctx.Done():
+		t.Fatal("timed out waiting for the job to be stopped")
+	}
+
+	// A job which starts within the timeout is unaffected
+	id, err = runner.RunWithOptions(ctx, &linesJob{count: 1}, steve.RunOptions{StartTimeout: time.Second})
+	require.NoError(t, err)
+	require.NoError(t, runner.Stop(ctx, id))
+}
+
+func TestSample(t *testing.T) {
+	runner := steve.NewJobRunner(20)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	var chunks []string
+	for i := 0; i < 100; i++ {
+		chunks = append(chunks, fmt.Sprintf("chunk %02d\n", i))
+	}
+	id, err := runner.RunWithOptions(ctx, &chunksJob{chunks: chunks}, steve.RunOptions{Sample: 0.1})
+	require.NoError(t, err)
+	require.NoError(t, runner.Stop(ctx, id))
+
+	testutil.UntilPass(t, 10, time.Millisecond*100, func(t testutil.TestingT) {
+		s, ok := runner.Status(id)
+		assert.True(t, ok)
+		assert.False(t, s.Running)
+	})
+
+	// Every tenth chunk is retained, while the total reflects all the output
+	data, _, err := runner.Snapshot(id)
+	require.NoError(t, err)
+	var expected string
+	for i := 9; i < 100; i += 10 {
+		expected += chunks[i]
+	}
+	assert.Equal(t, expected, string(data))
+
+	s, ok := runner.Status(id)
+	require.True(t, ok)
+	assert.Equal(t, 900, s.BytesWritten)
+	assert.Equal(t, 90, s.Retained)
+}
+
+func TestRestart(t *testing.T) {
+	runner := steve.NewJobRunner(20)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	stopped := func(id steve.ID) {
+		require.NoError(t, runner.Stop(ctx, id))
+		testutil.UntilPass(t, 10, time.Millisecond*100, func(t testutil.TestingT) {
+			s, ok := runner.Status(id)
+			assert.True(t, ok)
+			assert.False(t, s.Running)
+		})
+	}
+
+	id, err := runner.Run(ctx, &linesJob{count: 2})
+	require.NoError(t, err)
+	assert.ErrorIs(t, runner.Restart(ctx, id, steve.RestartOptions{}), steve.ErrJobRunning)
+	stopped(id)
+
+	_, offset, err := runner.Snapshot(id)
+	require.NoError(t, err)
+
+	// The output of the previous run is retained, and new output is appended after the separator
+	require.NoError(t, runner.Restart(ctx, id, steve.RestartOptions{PreserveOutput: true}))
+	stopped(id)
+	data, _, err := runner.Snapshot(id)
+	require.NoError(t, err)
+	assert.Equal(t, "line: 0\nline: 1\n"+steve.RestartSeparator+"line: 0\nline: 1\n", string(data))
+
+	// A reader resuming from an offset of the previous run sees continuous output
+	r, _, err := runner.NewResumableReader(id, offset)
+	require.NoError(t, err)
+	out, err := io.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, steve.RestartSeparator+"line: 0\nline: 1\n", string(out))
+
+	// Without preserving, the output of the previous runs is discarded
+	require.NoError(t, runner.Restart(ctx, id, steve.RestartOptions{}))
+	stopped(id)
+	data, _, err = runner.Snapshot(id)
+	require.NoError(t, err)
+	assert.Equal(t, "line: 0\nline: 1\n", string(data))
+	assert.Len(t, runner.List(), 1)
+
+	assert.ErrorIs(t, runner.Restart(ctx, "unknown", steve.RestartOptions{}), steve.ErrJobNotFound)
+}
+
+func TestRestartLiveReader(t *testing.T) {
+	runner := steve.NewJobRunner(20)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	defer func() { require.NoError(t, runner.Close(ctx)) }()
+
+	id, err := runner.RunWithOptions(ctx, &linesJob{count: 100}, steve.RunOptions{FreeOnStopIfUnread: true})
+	require.NoError(t, err)
+	r, err := runner.NewReader(id)
+	require.NoError(t, err)
+	require.NoError(t, runner.Stop(ctx, id))
+	testutil.UntilPass(t, 10, time.Millisecond*100, func(t testutil.TestingT) {
+		s, ok := runner.Status(id)
+		assert.True(t, ok)
+		assert.False(t, s.Running)
+	})
+
+	// The reader of the previous run reads the store while the next run writes to it
+	read := make(chan []byte)
+	go func() {
+		out, _ := io.ReadAll(r)
+		read <- out
+	}()
+	require.NoError(t, runner.Restart(ctx, id, steve.RestartOptions{PreserveOutput: true}))
+	out := <-read
+
+	var expected string
+	for i := 0; i < 100; i++ {
+		expected += fmt.Sprintf("line: %d\n", i)
+	}
+	assert.True(t, strings.HasPrefix(string(out), expected))
+
+	// The preserved output was read during the previous run, so it is not freed once the restarted job stops
+	require.NoError(t, runner.Stop(ctx, id))
+	testutil.UntilPass(t, 10, time.Millisecond*100, func(t testutil.TestingT) {
+		s, ok := runner.Status(id)
+		assert.True(t, ok)
+		assert.False(t, s.Running)
+	})
+	data, _, err := runner.Snapshot(id)
+	require.NoError(t, err)
+	assert.Equal(t, expected+steve.RestartSeparator+expected, string(data))
+}
+
+func TestRestartDiscardedOutput(t *testing.T) {
+	runner := steve.NewJobRunner(20)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	defer func() { require.NoError(t, runner.Close(ctx)) }()
+
+	stopped := func(id steve.ID) {
+		require.NoError(t, runner.Stop(ctx, id))
+		testutil.UntilPass(t, 10, time.Millisecond*100, func(t testutil.TestingT) {
+			s, ok := runner.Status(id)
+			assert.True(t, ok)
+			assert.False(t, s.Running)
+		})
+	}
+
+	id, err := runner.RunWithOptions(ctx, &linesJob{count: 2}, steve.RunOptions{FreeOnStopIfUnread: true})
+	require.NoError(t, err)
+	stopped(id)
+	_, _, err = runner.Snapshot(id)
+	require.ErrorIs(t, err, steve.ErrOutputDiscarded)
+
+	// The discarded output is not preserved, the next run starts with empty output
+	require.NoError(t, runner.Restart(ctx, id, steve.RestartOptions{PreserveOutput: true}))
+	r, err := runner.NewReader(id)
+	require.NoError(t, err)
+	stopped(id)
+	out, err := io.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, "line: 0\nline: 1\n", string(out))
+
+	data, offset, err := runner.Snapshot(id)
+	require.NoError(t, err)
+	assert.Equal(t, "line: 0\nline: 1\n", string(data))
+	assert.Equal(t, len(data), offset)
+	hash, err := runner.OutputHash(id)
+	require.NoError(t, err)
+	sum := sha256.Sum256(data)
+	assert.Equal(t, sum[:], hash)
+}
+
+func TestListPage(t *testing.T) {
+	runner := steve.NewJobRunner(20)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	var ids []steve.ID
+	for i := 0; i < 7; i++ {
+		id, err := runner.Run(ctx, &linesJob{count: 1})
+		require.NoError(t, err)
+		ids = append(ids, id)
+	}
+
+	// Paging through the jobs lists every job once in the order started
+	var listed []steve.ID
+	for offset := 0; ; offset += 3 {
+		page, total := runner.ListPage(offset, 3)
+		assert.Equal(t, 7, total)
+		if len(page) == 0 {
+			break
+		}
+		assert.LessOrEqual(t, len(page), 3)
+		for _, s := range page {
+			listed = append(listed, s.ID)
+		}
+	}
+	assert.Equal(t, ids, listed)
+
+	page, total := runner.ListPage(0, 0)
+	assert.Len(t, page, 7)
+	assert.Equal(t, 7, total)
+
+	page, total = runner.ListPage(100, 3)
+	assert.Empty(t, page)
+	assert.Equal(t, 7, total)
+}
+
+// panicJob panics in Start
+type panicJob struct{}
+
+func (p *panicJob) Start(ctx context.Context, writer io.Writer) error {
+	panic("boom")
+}
+
+func (p *panicJob) Stop(ctx context.Context) error {
+	return nil
+}
+
+func TestJobPanic(t *testing.T) {
+	runner := steve.NewJobRunner(20)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	stopped := func(id steve.ID) steve.Status {
+		var status steve.Status
+		testutil.UntilPass(t, 10, time.Millisecond*100, func(t testutil.TestingT) {
+			s, ok := runner.Status(id)
+			assert.True(t, ok)
+			assert.False(t, s.Running)
+			status = s
+		})
+		return status
+	}
+
+	// A panic in Start fails the job, which is retained such that the panic is reported
+	id, err := runner.Run(ctx, &panicJob{})
+	assert.ErrorIs(t, err, steve.ErrJobPanicked)
+	require.NotEmpty(t, id)
+	s := stopped(id)
+	assert.Equal(t, "job panicked: boom", s.Err)
+
+	// A panic in a callback fails the job
+	id, err = runner.RunWithOptions(ctx, &linesJob{count: 10}, steve.RunOptions{
+		Transform: func(b []byte) []byte { panic("transform") },
+	})
+	require.NoError(t, err)
+	s = stopped(id)
+	assert.Equal(t, "job panicked: transform", s.Err)
+
+	// The runner survives and continues to run jobs
+	id, err = runner.Run(ctx, &linesJob{count: 1})
+	require.NoError(t, err)
+	require.NoError(t, runner.Stop(ctx, id))
+	s = stopped(id)
+	assert.Empty(t, s.Err)
+	assert.Len(t, runner.List(), 3)
+}
+
+func TestJobPanicNoGoroutineLeaks(t *testing.T) {
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	stopped := func(runner steve.Runner, id steve.ID) steve.Status {
+		var status steve.Status
+		testutil.UntilPass(t, 10, time.Millisecond*100, func(t testutil.TestingT) {
+			s, ok := runner.Status(id)
+			assert.True(t, ok)
+			assert.False(t, s.Running)
+			status = s
+		})
+		return status
+	}
+
+	// The read go routine exits although it was sending output when Transform panicked
+	runner := steve.NewJobRunner(20)
+	id, err := runner.RunWithOptions(ctx, &chunksJob{chunks: []string{"one\n", "two\n", "three\n"}},
+		steve.RunOptions{Transform: func(b []byte) []byte { panic("transform") }})
+	require.NoError(t, err)
+	assert.Equal(t, "job panicked: transform", stopped(runner, id).Err)
+	require.NoError(t, runner.Close(ctx))
+
+	// A panic while flushing a partial line to the logger fails the job
+	runner = steve.NewJobRunner(20, steve.WithOutputLogger(func(id steve.ID, line []byte) {
+		if !bytes.HasSuffix(line, []byte("\n")) {
+			panic("logger")
+		}
+	}))
+	id, err = runner.Run(ctx, &chunksJob{chunks: []string{"one\n", "partial"}})
+	require.NoError(t, err)
+	require.NoError(t, runner.Stop(ctx, id))
+	assert.Equal(t, "job panicked: logger", stopped(runner, id).Err)
+	require.NoError(t, runner.Close(ctx))
+}
+
+func TestTimestampLines(t *testing.T) {
+	runner := steve.NewJobRunner(20)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	// Lines split across chunks are prefixed once
+	before := time.Now()
+	id, err := runner.RunWithOptions(ctx, &chunksJob{chunks: []string{"one\ntw", "o\n", "three\n"}},
+		steve.RunOptions{TimestampLines: true})
+	require.NoError(t, err)
+
+	r, err := runner.NewReader(id)
+	require.NoError(t, err)
+	require.NoError(t, runner.Stop(ctx, id))
+	out, err := io.ReadAll(r)
+	require.NoError(t, err)
+
+	var lines []string
+	last := before
+	s := bufio.NewScanner(bytes.NewReader(out))
+	for s.Scan() {
+		stamp, line, ok := strings.Cut(s.Text(), " ")
+		require.True(t, ok)
+		ts, err := time.Parse(time.RFC3339Nano, stamp)
+		require.NoError(t, err)
+		assert.False(t, ts.Before(last), "timestamps are monotonic")
+		last = ts
+		lines = append(lines, line)
+	}
+	assert.Equal(t, []string{"one", "two", "three"}, lines)
+}
+
+func TestMirrors(t *testing.T) {
+	runner := steve.NewJobRunner(20)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	// A mirror whose reader has been closed fails every write with io.ErrClosedPipe
+	pr, broken := io.Pipe()
+	require.NoError(t, pr.Close())
+	var good bytes.Buffer
+
+	var mutex sync.Mutex
+	var failed []error
+	id, err := runner.RunWithOptions(ctx, &linesJob{count: 100}, steve.RunOptions{
+		Mirrors: []io.Writer{broken, &good},
+		MirrorError: func(w io.Writer, err error) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			assert.Equal(t, broken, w)
+			failed = append(failed, err)
+		},
+	})
+	require.NoError(t, err)
+	require.NoError(t, runner.Stop(ctx, id))
+
+	testutil.UntilPass(t, 10, time.Millisecond*100, func(t testutil.TestingT) {
+		s, ok := runner.Status(id)
+		assert.True(t, ok)
+		assert.False(t, s.Running)
+	})
+
+	// The broken mirror is removed once, and neither the job nor the other mirror are affected
+	data, _, err := runner.Snapshot(id)
+	require.NoError(t, err)
+	assert.Equal(t, 890, len(data))
+	assert.Equal(t, string(data), good.String())
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	require.Len(t, failed, 1)
+	assert.ErrorIs(t, failed[0], io.ErrClosedPipe)
+}
+
+func TestRequireRunning(t *testing.T) {
+	runner := steve.NewJobRunner(20)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	id, err := runner.Run(ctx, &linesJob{count: 1})
+	require.NoError(t, err)
+	require.NoError(t, runner.Stop(ctx, id))
+	testutil.UntilPass(t, 10, time.Millisecond*100, func(t testutil.TestingT) {
+		s, ok := runner.Status(id)
+		assert.True(t, ok)
+		assert.False(t, s.Running)
+	})
+
+	// Every operation which requires a running job reports a stopped job the same way
+	for name, op := range map[string]func() error{
+		"Stop":         func() error { return runner.Stop(ctx, id) },
+		"TryStop":      func() error { return runner.TryStop(id) },
+		"StopGraceful": func() error { return runner.StopGraceful(ctx, id, time.Second) },
+		"Flush":        func() error { return runner.Flush(id) },
+		"Pause":        func() error { return runner.Pause(id) },
+		"Resume":       func() error { return runner.Resume(id) },
+	} {
+		assert.ErrorIs(t, op(), steve.ErrJobNotRunning, name)
+	}
+}
+
+func TestMaxConcurrent(t *testing.T) {
+	runner := steve.NewJobRunner(20, steve.WithMaxConcurrent(1))
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	running := newWriterJob()
+	a, err := runner.Run(ctx, running)
+	require.NoError(t, err)
+
+	// Jobs are queued while the maximum number of jobs are running
+	canceled := &flakyJob{}
+	b, err := runner.Run(ctx, canceled)
+	require.NoError(t, err)
+	queued := &flakyJob{}
+	c, err := runner.Run(ctx, queued)
+	require.NoError(t, err)
+
+	for _, id := range []steve.ID{b, c} {
+		s, ok := runner.Status(id)
+		require.True(t, ok)
+		assert.True(t, s.Pending)
+		assert.False(t, s.Running)
+	}
+
+	// Stopping a queued job removes it from the queue, it is never started
+	require.NoError(t, runner.Stop(ctx, b))
+	testutil.UntilPass(t, 10, time.Millisecond*100, func(t testutil.TestingT) {
+		s, ok := runner.Status(b)
+		assert.True(t, ok)
+		assert.False(t, s.Running)
+		assert.False(t, s.Pending)
+		assert.True(t, s.Canceled)
+	})
+
+	// The next queued job starts once the running job stops
+	close(running.lines)
+	<-running.done
+	require.NoError(t, runner.Stop(ctx, a))
+	testutil.UntilPass(t, 10, time.Millisecond*100, func(t testutil.TestingT) {
+		s, ok := runner.Status(c)
+		assert.True(t, ok)
+		assert.False(t, s.Running)
+		assert.False(t, s.Pending)
+		assert.False(t, s.Canceled)
+	})
+	assert.Equal(t, int64(1), atomic.LoadInt64(&queued.starts))
+	assert.Equal(t, int64(0), atomic.LoadInt64(&canceled.starts))
+
+	s, ok := runner.Status(a)
+	require.True(t, ok)
+	assert.False(t, s.Canceled)
+
+	// A limit which would never start a job is not allowed
+	assert.Panics(t, func() { steve.WithMaxConcurrent(0) })
+	assert.Panics(t, func() { steve.WithMaxConcurrent(-1) })
+}
+
+// echoJob echoes its input to the output, completing once the input is closed
+type echoJob struct{}
+
+func (e *echoJob) Start(ctx context.Context, writer io.Writer) error {
+	return errors.New("echoJob requires input")
+}
+
+func (e *echoJob) StartWithInput(ctx context.Context, writer io.Writer, input io.Reader) error {
+	go func() {
+		_, err := io.Copy(writer, input)
+		_ = writer.(*io.PipeWriter).CloseWithError(err)
+	}()
+	return nil
+}
+
+func (e *echoJob) Stop(ctx context.Context) error {
+	return nil
+}
+
+func TestInput(t *testing.T) {
+	runner := steve.NewJobRunner(20)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	id, err := runner.Run(ctx, &echoJob{})
+	require.NoError(t, err)
+
+	r, err := runner.NewReader(id)
+	require.NoError(t, err)
+
+	w, err := runner.Input(id)
+	require.NoError(t, err)
+	_, err = io.WriteString(w, "hello\n")
+	require.NoError(t, err)
+	_, err = io.WriteString(w, "world\n")
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	// Closing the input completes the job
+	out, err := io.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, "hello\nworld\n", string(out))
+
+	testutil.UntilPass(t, 10, time.Millisecond*100, func(t testutil.TestingT) {
+		_, err := runner.Input(id)
+		assert.ErrorIs(t, err, steve.ErrJobNotRunning)
+	})
+
+	id, err = runner.Run(ctx, newWriterJob())
+	require.NoError(t, err)
+	_, err = runner.Input(id)
+	assert.ErrorIs(t, err, steve.ErrNoInput)
+
+	_, err = runner.Input("unknown")
+	assert.ErrorIs(t, err, steve.ErrJobNotFound)
+}
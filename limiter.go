@@ -0,0 +1,82 @@
+package steve
+
+import (
+	"io"
+	"time"
+)
+
+// limiter is a token bucket which limits the rate at which bytes are written to a reader
+type limiter struct {
+	rate   int
+	burst  int
+	tokens float64
+	last   time.Time
+	clock  Clock
+}
+
+func newLimiter(bytesPerSec int, clock Clock) *limiter {
+	// Allow bursts of a tenth of a second worth of bytes
+	burst := bytesPerSec / 10
+	if burst < 1 {
+		burst = 1
+	}
+	return &limiter{
+		rate:   bytesPerSec,
+		burst:  burst,
+		tokens: float64(burst),
+		last:   clock.Now(),
+		clock:  clock,
+	}
+}
+
+// take waits until at least one byte may be written, and returns the number of bytes up to
+// max which may be written now. Returns false if closed is closed while waiting.
+func (l *limiter) take(max int, closed <-chan struct{}) (int, bool) {
+	for {
+		now := l.clock.Now()
+		l.tokens += now.Sub(l.last).Seconds() * float64(l.rate)
+		if l.tokens > float64(l.burst) {
+			l.tokens = float64(l.burst)
+		}
+		l.last = now
+
+		if l.tokens >= 1 {
+			n := int(l.tokens)
+			if n > max {
+				n = max
+			}
+			l.tokens -= float64(n)
+			return n, true
+		}
+
+		// Wait until the next byte is available
+		wait := time.Duration((1 - l.tokens) / float64(l.rate) * float64(time.Second))
+		select {
+		case <-l.clock.After(wait):
+		case <-closed:
+			return 0, false
+		}
+	}
+}
+
+// write writes b to the writer no faster than the limiter allows. If the limiter
+// is nil, b is written without limit.
+func (l *limiter) write(w io.Writer, b []byte, closed <-chan struct{}) (int, error) {
+	if l == nil {
+		return w.Write(b)
+	}
+
+	var written int
+	for written < len(b) {
+		allowed, ok := l.take(len(b)-written, closed)
+		if !ok {
+			return written, io.ErrClosedPipe
+		}
+		n, err := w.Write(b[written : written+allowed])
+		written += n
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
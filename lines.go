@@ -0,0 +1,127 @@
+package steve
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+)
+
+// lineBuffer splits chunks of output into complete lines, buffering
+// any partial line until the remainder of the line is written.
+type lineBuffer struct {
+	partial []byte
+	// max if not zero, is the maximum length of a line excluding the newline, longer
+	// lines are split into fragments of max bytes which have no trailing newline.
+	max int
+}
+
+// Split returns all the complete lines, including the trailing newline,
+// found in the provided chunk combined with any previously buffered partial line.
+func (l *lineBuffer) Split(chunk []byte) [][]byte {
+	var lines [][]byte
+	for {
+		i := bytes.IndexByte(chunk, '\n')
+		if l.max != 0 {
+			length := i
+			if i == -1 {
+				length = len(chunk)
+			}
+			if room := l.max - len(l.partial); length > room {
+				lines = append(lines, append(l.partial, chunk[:room]...))
+				l.partial = nil
+				chunk = chunk[room:]
+				continue
+			}
+		}
+		if i == -1 {
+			l.partial = append(l.partial, chunk...)
+			return lines
+		}
+		line := append(l.partial, chunk[:i+1]...)
+		l.partial = nil
+		lines = append(lines, line)
+		chunk = chunk[i+1:]
+	}
+}
+
+// Flush returns any buffered partial line, or nil if there is none
+func (l *lineBuffer) Flush() []byte {
+	line := l.partial
+	l.partial = nil
+	return line
+}
+
+// collapser collapses consecutive identical lines into a single line with a count suffix,
+// such that a line repeated 42 times is stored once as "line (x42)". As the number of
+// repeats is unknown until the line changes, each line is held back until a different
+// line is written or the collapser is flushed.
+type collapser struct {
+	lines lineBuffer
+	last  []byte
+	count int
+}
+
+// Write returns the output which is ready to be stored after collapsing the provided chunk
+func (c *collapser) Write(chunk []byte) []byte {
+	var out []byte
+	for _, line := range c.lines.Split(chunk) {
+		if c.count != 0 && bytes.Equal(line, c.last) {
+			c.count++
+			continue
+		}
+		out = c.appendLast(out)
+		c.last = line
+		c.count = 1
+	}
+	return out
+}
+
+// Flush returns the held back line and any partial line
+func (c *collapser) Flush() []byte {
+	out := c.appendLast(nil)
+	c.last = nil
+	c.count = 0
+	return append(out, c.lines.Flush()...)
+}
+
+func (c *collapser) appendLast(out []byte) []byte {
+	switch c.count {
+	case 0:
+		return out
+	case 1:
+		return append(out, c.last...)
+	}
+	line := bytes.TrimSuffix(c.last, []byte("\n"))
+	return append(out, fmt.Sprintf("%s (x%d)\n", line, c.count)...)
+}
+
+// timestamper prefixes each line with the time the first byte of the line was written. Partial
+// lines are passed through without waiting for the rest of the line, as only the start of a
+// line is prefixed.
+type timestamper struct {
+	// midLine is true if the last chunk ended without a newline
+	midLine bool
+}
+
+// Write returns the chunk with the provided time prefixed to each line which begins in the chunk
+func (t *timestamper) Write(chunk []byte, now time.Time) []byte {
+	if len(chunk) == 0 {
+		return chunk
+	}
+	prefix := now.UTC().Format(time.RFC3339Nano) + " "
+	out := make([]byte, 0, len(chunk)+len(prefix))
+	for len(chunk) != 0 {
+		if !t.midLine {
+			out = append(out, prefix...)
+		}
+		i := bytes.IndexByte(chunk, '\n')
+		if i == -1 {
+			t.midLine = true
+			return append(out, chunk...)
+		}
+		out = append(out, chunk[:i+1]...)
+		t.midLine = false
+		chunk = chunk[i+1:]
+	}
+	return out
+}
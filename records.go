@@ -0,0 +1,112 @@
+package steve
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+)
+
+// JSONLinesStore is an OutputStore for jobs which emit one JSON record per line. Like the
+// BytesBufferStore it retains all output written to it, but only complete lines are retained,
+// a partial line is held back until the rest of the line is written. As a result readers
+// only ever receive whole records.
+type JSONLinesStore struct {
+	store BytesBufferStore
+	lines lineBuffer
+}
+
+func NewJSONLinesStore() *JSONLinesStore {
+	return &JSONLinesStore{}
+}
+
+func (s *JSONLinesStore) Write(b []byte) (int, error) {
+	for _, line := range s.lines.Split(b) {
+		if _, err := s.store.Write(line); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+func (s *JSONLinesStore) ReadOffset(offset int) ([]byte, int) {
+	return s.store.ReadOffset(offset)
+}
+
+func (s *JSONLinesStore) Len() int {
+	return s.store.Len()
+}
+
+// Record is a single line of output read by a RecordReader
+type Record struct {
+	// Data is the line without the trailing newline
+	Data []byte
+	// Valid is true if Data is valid JSON
+	Valid bool
+	// Continued is true if the line exceeded RecordOptions.MaxLineBytes and Data is only a fragment
+	// of the line, the remainder of the line is returned by the following records.
+	Continued bool
+}
+
+// RecordOptions are the options for readers created by NewRecordReader
+type RecordOptions struct {
+	// SkipInvalid if true, lines which are not valid JSON are not returned by the reader
+	SkipInvalid bool
+	// MaxLineBytes if not zero, is the maximum length of a line excluding the newline. Longer lines
+	// are returned as several records of at most MaxLineBytes, all but the last of which are marked
+	// as Continued, such that a job which never writes a newline can't buffer unbounded memory.
+	MaxLineBytes int
+}
+
+// RecordReader reads the output of a job one complete line at a time. A partial
+// line at the end of the output of a stopped job is never returned.
+type RecordReader struct {
+	reader  io.ReadCloser
+	opts    RecordOptions
+	lines   lineBuffer
+	records [][]byte
+	buf     []byte
+}
+
+// Next returns the next record, blocking until a complete line is available. Returns
+// io.EOF once the job has stopped and all complete lines have been returned.
+func (r *RecordReader) Next() (Record, error) {
+	for {
+		for len(r.records) != 0 {
+			line := r.records[0]
+			r.records = r.records[1:]
+
+			// Only a fragment of a line which exceeded MaxLineBytes has no trailing newline
+			rec := Record{Data: bytes.TrimSuffix(line, []byte("\n"))}
+			rec.Continued = len(rec.Data) == len(line)
+			rec.Valid = json.Valid(rec.Data)
+			if !rec.Valid && r.opts.SkipInvalid {
+				continue
+			}
+			return rec, nil
+		}
+
+		n, err := r.reader.Read(r.buf)
+		r.records = append(r.records, r.lines.Split(r.buf[:n])...)
+		if err != nil && len(r.records) == 0 {
+			return Record{}, err
+		}
+	}
+}
+
+// Close detaches the reader from the job
+func (r *RecordReader) Close() error {
+	return r.reader.Close()
+}
+
+func (r *runner) NewRecordReader(id ID, opts RecordOptions) (*RecordReader, error) {
+	reader, err := r.NewReader(id)
+	if err != nil {
+		return nil, err
+	}
+	return &RecordReader{
+		reader: reader,
+		opts:   opts,
+		lines:  lineBuffer{max: opts.MaxLineBytes},
+		buf:    make([]byte, 2024),
+	}, nil
+}
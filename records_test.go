@@ -0,0 +1,139 @@
+package steve_test
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thrawn01/steve"
+)
+
+// chunksJob writes each chunk to the writer as a separate write
+type chunksJob struct {
+	chunks []string
+}
+
+func (c *chunksJob) Start(ctx context.Context, writer io.Writer) error {
+	for _, chunk := range c.chunks {
+		_, _ = io.WriteString(writer, chunk)
+	}
+	return nil
+}
+
+func (c *chunksJob) Stop(ctx context.Context) error {
+	return nil
+}
+
+func TestJSONLinesStore(t *testing.T) {
+	s := steve.NewJSONLinesStore()
+
+	_, err := s.Write([]byte(`{"a":1}` + "\n" + `{"b"`))
+	require.NoError(t, err)
+	data, offset := s.ReadOffset(0)
+	assert.Equal(t, `{"a":1}`+"\n", string(data))
+
+	// The partial line is retained once the rest of the line is written
+	_, err = s.Write([]byte(`:2}` + "\n"))
+	require.NoError(t, err)
+	data, _ = s.ReadOffset(offset)
+	assert.Equal(t, `{"b":2}`+"\n", string(data))
+	assert.Equal(t, 16, s.Len())
+}
+
+func TestNewRecordReader(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		opts     steve.RecordOptions
+		expected []steve.Record
+	}{
+		{
+			name: "All",
+			expected: []steve.Record{
+				{Data: []byte(`{"a":1}`), Valid: true},
+				{Data: []byte(`{"b":2}`), Valid: true},
+				{Data: []byte(`not json`), Valid: false},
+				{Data: []byte(`{"c":3}`), Valid: true},
+			},
+		},
+		{
+			name: "SkipInvalid",
+			opts: steve.RecordOptions{SkipInvalid: true},
+			expected: []steve.Record{
+				{Data: []byte(`{"a":1}`), Valid: true},
+				{Data: []byte(`{"b":2}`), Valid: true},
+				{Data: []byte(`{"c":3}`), Valid: true},
+			},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			runner := steve.NewJobRunner(20)
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+			defer cancel()
+
+			// Records split across writes, ending with a partial record
+			id, err := runner.Run(ctx, &chunksJob{chunks: []string{
+				`{"a":1}` + "\n" + `{"b"`,
+				`:2}` + "\n" + "not json\n" + `{"c":3}`,
+				"\n" + `{"d":`,
+			}})
+			require.NoError(t, err)
+
+			r, err := runner.NewRecordReader(id, test.opts)
+			require.NoError(t, err)
+			defer r.Close()
+			require.NoError(t, runner.Stop(ctx, id))
+
+			var records []steve.Record
+			for {
+				rec, err := r.Next()
+				if err == io.EOF {
+					break
+				}
+				require.NoError(t, err)
+				records = append(records, rec)
+			}
+			assert.Equal(t, test.expected, records)
+		})
+	}
+}
+
+func TestRecordReaderMaxLineBytes(t *testing.T) {
+	runner := steve.NewJobRunner(20)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	// A huge line without a newline, followed by a line of exactly MaxLineBytes
+	blob := strings.Repeat("x", 1_000_000)
+	id, err := runner.Run(ctx, &chunksJob{chunks: []string{blob, "\n", strings.Repeat("y", 1024) + "\n"}})
+	require.NoError(t, err)
+
+	r, err := runner.NewRecordReader(id, steve.RecordOptions{MaxLineBytes: 1024})
+	require.NoError(t, err)
+	defer r.Close()
+	require.NoError(t, runner.Stop(ctx, id))
+
+	var records []steve.Record
+	for {
+		rec, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		assert.LessOrEqual(t, len(rec.Data), 1024)
+		records = append(records, rec)
+	}
+
+	// All but the last fragment of the huge line are marked as continued
+	require.Len(t, records, 978)
+	var line []byte
+	for i, rec := range records[:977] {
+		line = append(line, rec.Data...)
+		assert.Equal(t, i != 976, rec.Continued)
+	}
+	assert.Equal(t, blob, string(line))
+	assert.Equal(t, steve.Record{Data: []byte(strings.Repeat("y", 1024))}, records[977])
+}
@@ -1,61 +1,171 @@
 package steve
 
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"sync/atomic"
+)
+
+var (
+	ErrDataDiscarded = errors.New("requested data has been discarded")
+	ErrOutOfRange    = errors.New("requested range is out of range")
+)
+
 // AllocSize is the initial allocation size of the buffer.
 // If the requested capacity is larger than this initial size,
 // then the internal buffer will grow to match the capacity
 // requested as bytes are written.
 const AllocSize = 512
 
+// noData is returned by ReadOffset when there is no new data to read, such
+// that readers polling for new data do not allocate. It has a capacity of
+// zero, so appending to it never modifies the shared slice.
+var noData = []byte{}
+
 type RingBuffer struct {
 	buffer   []byte
 	capacity int
-	total    int
+	total    int64
+	floor    int
 	wpos     int
+	grows    int
+}
+
+// RingStats are the statistics of a RingBuffer returned by Stats
+type RingStats struct {
+	// Capacity is the maximum number of bytes the ring retains
+	Capacity int
+	// Len is the number of bytes currently retained
+	Len int
+	// Offset is the total number of bytes written
+	Offset int
+	// Discarded is the number of bytes written which are no longer retained
+	Discarded int
+	// Grows is the number of times the ring reallocated to grow towards the capacity
+	Grows int
 }
 
 func NewRingBuffer(capacity int) *RingBuffer {
-	if capacity == 0 {
-		panic("NewRingBuffer: A capacity of zero is not allowed")
-	}
+	r := newRingBuffer("NewRingBuffer", capacity)
+	r.alloc()
+	return r
+}
 
-	size := capacity
+// alloc allocates the initial buffer of the ring
+func (r *RingBuffer) alloc() {
+	size := r.capacity
 	// Only allocate the initial size of bytes at first
 	if size > AllocSize {
 		size = AllocSize
 	}
+	r.buffer = make([]byte, size)
+}
+
+// NewRingBufferLazy is identical to NewRingBuffer but defers allocating
+// the initial AllocSize bytes until the first Write, such that a ring
+// which is never written to allocates nothing. Capacity returns zero
+// until the first Write.
+func NewRingBufferLazy(capacity int) *RingBuffer {
+	return newRingBuffer("NewRingBufferLazy", capacity)
+}
 
+// newRingBuffer returns a ring with the provided capacity which has not allocated
+// a buffer, panics using the name of the constructor if the capacity is invalid.
+func newRingBuffer(name string, capacity int) *RingBuffer {
+	if capacity == 0 {
+		panic(name + ": A capacity of zero is not allowed")
+	}
+	if capacity < 0 {
+		panic(fmt.Sprintf("%s: A negative capacity of %d is not allowed", name, capacity))
+	}
 	return &RingBuffer{
-		buffer:   make([]byte, size),
 		capacity: capacity,
 		wpos:     0,
 	}
 }
 
+// Reset discards all the bytes written to the ring, such that the ring can be reused
+// as if it was newly constructed with the same capacity, see ResetCapacity.
+func (r *RingBuffer) Reset() {
+	r.ResetCapacity(r.capacity)
+}
+
+// ResetCapacity discards all the bytes written to the ring and sets a new capacity,
+// such that pooled rings can be reused for jobs with different size expectations.
+// The ring reallocates the initial size of bytes and grows towards the new capacity
+// exactly as a ring returned by NewRingBuffer. Offsets begin again from zero.
+func (r *RingBuffer) ResetCapacity(capacity int) {
+	r.capacity = newRingBuffer("ResetCapacity", capacity).capacity
+	r.floor = 0
+	r.wpos = 0
+	r.grows = 0
+	r.alloc()
+	atomic.StoreInt64(&r.total, 0)
+}
+
 func (r *RingBuffer) Write(b []byte) {
-	// Do we need to consider growing the size of our buffer?
-	if r.capacity != cap(r.buffer) && r.total <= r.capacity {
-		// Is there room in the current buffer for this write?
-		if r.total+len(b) > cap(r.buffer) {
-			size := r.total + len(b)
-			if size < 2*cap(b) {
-				// Avoid making small allocations, go big or go home.
-				size = 2 * cap(b)
-				// But only allocate as much as our max capacity.
-				if size > r.capacity {
-					size = r.capacity
-				}
-			}
-			b2 := make([]byte, size)
-			copy(b2, r.buffer)
-			r.buffer = b2
-		}
-	}
-
-	r.total += len(b)
+	r.grow(len(b))
+
 	for _, v := range b {
 		r.buffer[r.wpos] = v
 		r.wpos = (r.wpos + 1) % r.capacity
 	}
+	// Update the total only after the bytes are in the ring, such
+	// that Offset() never reports bytes which are not yet written.
+	atomic.AddInt64(&r.total, int64(len(b)))
+}
+
+// Grow ensures the ring has allocated enough space to hold another
+// n bytes without reallocating, up to the capacity of the ring. Use
+// Grow ahead of a known large write to avoid repeated reallocation.
+func (r *RingBuffer) Grow(n int) {
+	r.grow(n)
+}
+
+func (r *RingBuffer) grow(n int) {
+	// Do we need to consider growing the size of our buffer?
+	if r.capacity == cap(r.buffer) || r.Offset() > r.capacity {
+		return
+	}
+
+	// Is there room in the current buffer for this write?
+	if r.Offset()+n <= cap(r.buffer) {
+		return
+	}
+
+	size := r.Offset() + n
+	if size < 2*n {
+		// Avoid making small allocations, go big or go home.
+		size = 2 * n
+	}
+	// The first allocation of a lazy ring is at least the initial size
+	initial := r.buffer == nil
+	if initial && size < AllocSize {
+		size = AllocSize
+	}
+	// But only allocate as much as our max capacity.
+	if size > r.capacity {
+		size = r.capacity
+	}
+	b2 := make([]byte, size)
+	copy(b2, r.buffer)
+	r.buffer = b2
+	if !initial {
+		r.grows++
+	}
+}
+
+// Stats returns statistics useful for tuning AllocSize and the capacity of the ring
+func (r *RingBuffer) Stats() RingStats {
+	return RingStats{
+		Capacity:  r.capacity,
+		Len:       r.Len(),
+		Offset:    r.Offset(),
+		Discarded: r.start(),
+		Grows:     r.grows,
+	}
 }
 
 // Bytes will return the entire buffer for the ring.
@@ -67,9 +177,56 @@ func (r *RingBuffer) Bytes() []byte {
 
 // Offset will return the current written offset which
 // can be used to start reading at the end of the current
-// buffer.
+// buffer. Offset is safe to call concurrently with Write.
 func (r *RingBuffer) Offset() int {
-	return r.total
+	return int(atomic.LoadInt64(&r.total))
+}
+
+// Len returns the number of bytes currently retained by
+// the ring buffer.
+func (r *RingBuffer) Len() int {
+	return r.Offset() - r.start()
+}
+
+// start returns the logical offset of the oldest byte
+// retained by the ring buffer.
+func (r *RingBuffer) start() int {
+	start := r.Offset() - r.capacity
+	if start < 0 {
+		start = 0
+	}
+	if r.floor > start {
+		start = r.floor
+	}
+	return start
+}
+
+// Truncate discards the oldest n retained bytes by advancing
+// the logical floor of the ring without reallocating. Reads
+// of offsets below the floor behave as if the data was
+// overwritten by the ring.
+func (r *RingBuffer) Truncate(n int) {
+	if n <= 0 {
+		return
+	}
+	r.floor = r.start() + n
+	if r.floor > r.Offset() {
+		r.floor = r.Offset()
+	}
+}
+
+// DiscardOldest discards up to n of the oldest retained bytes
+// and returns the number of bytes discarded, which is less
+// than n if fewer than n bytes are retained.
+func (r *RingBuffer) DiscardOldest(n int) int {
+	if n <= 0 {
+		return 0
+	}
+	if l := r.Len(); n > l {
+		n = l
+	}
+	r.Truncate(n)
+	return n
 }
 
 // Capacity returns the total number of bytes allocated for
@@ -78,12 +235,22 @@ func (r *RingBuffer) Capacity() int {
 	return cap(r.buffer)
 }
 
+// ReadOffset returns the bytes written after the provided offset and the
+// offset to provide to the next call to ReadOffset. If there is no new data
+// an empty slice is returned without allocating. The returned bytes are
+// always a copy which never aliases the internal buffer, such that they
+// remain unchanged by later writes to the ring.
 func (r *RingBuffer) ReadOffset(offset int) ([]byte, int) {
+	// Never read below the floor set by Truncate
+	if r.floor > offset {
+		offset = r.floor
+	}
+
 	// If the offset is the same or outside the bounds
 	// of the total written, then return empty bytes
 	// and the current total.
-	if offset >= r.total {
-		return []byte(""), r.total
+	if offset >= r.Offset() {
+		return noData, r.Offset()
 	}
 
 	// Given the requested offset, calculate where in
@@ -94,15 +261,17 @@ func (r *RingBuffer) ReadOffset(offset int) ([]byte, int) {
 	// we don't have that data anymore. In this case, we return the
 	// entire buffer contents starting from the current Write position.
 	// OR
-	// If our read position is the same as the current Write position, this
-	// means we are a full ring cycle behind and need to read the entire ring.
-	if offset < (r.total-r.capacity) || pos == r.wpos {
+	// If we are exactly a full ring cycle behind, our read position is the
+	// same as the current Write position and we need to read the entire ring.
+	// This can only occur once the ring is full, as until then the Write
+	// position is the total written which is always past the offset.
+	if offset <= (r.Offset() - r.capacity) {
 		data := make([]byte, r.capacity)
 		// Copy bytes from the current Write position until the end of the buffer
 		copy(data, r.buffer[r.wpos:r.capacity])
 		// Read from the beginning of the buffer until the last Write position.
 		copy(data[r.capacity-r.wpos:], r.buffer[:r.wpos])
-		return data, r.total
+		return data, r.Offset()
 	}
 
 	if r.wpos < pos {
@@ -118,3 +287,213 @@ func (r *RingBuffer) ReadOffset(offset int) ([]byte, int) {
 	copy(data, r.buffer[pos:r.wpos])
 	return data, offset + len(data)
 }
+
+// ReadOffsetChecked is identical to ReadOffset but also returns true if
+// some of the bytes written after the provided offset are no longer
+// retained, such that there is a gap between the offset and the first
+// returned byte. Callers can use this to indicate output was truncated.
+func (r *RingBuffer) ReadOffsetChecked(offset int) ([]byte, int, bool) {
+	data, next, skipped := r.ReadOffsetSkipped(offset)
+	return data, next, skipped != 0
+}
+
+// ReadOffsetSkipped is identical to ReadOffset but also returns the number of
+// bytes written after the provided offset which are no longer retained, such
+// that a reader resuming from a stale offset knows exactly how much it missed.
+func (r *RingBuffer) ReadOffsetSkipped(offset int) ([]byte, int, int) {
+	var skipped int
+	if start := r.start(); offset < start {
+		skipped = start - offset
+	}
+	data, next := r.ReadOffset(offset)
+	return data, next, skipped
+}
+
+// ReadRange returns a copy of the bytes within the logical range [start, end)
+// of all the bytes written to the ring. Returns ErrOutOfRange if the range
+// is invalid or extends beyond the bytes written, and ErrDataDiscarded if
+// any part of the requested range is no longer retained by the ring.
+func (r *RingBuffer) ReadRange(start, end int) ([]byte, error) {
+	if start < 0 || start > end || end > r.Offset() {
+		return nil, ErrOutOfRange
+	}
+
+	if start < r.start() {
+		return nil, ErrDataDiscarded
+	}
+
+	data := make([]byte, end-start)
+	r.copyAt(data, start)
+	return data, nil
+}
+
+// ReadAll returns a copy of all the bytes retained
+// by the ring in the order they were written.
+func (r *RingBuffer) ReadAll() []byte {
+	data := make([]byte, r.Len())
+	r.copyAt(data, r.Offset()-len(data))
+	return data
+}
+
+// NewReader returns a reader over a snapshot of all the bytes retained by
+// the ring at the time of the call. Bytes written after the call are not
+// returned by the reader.
+func (r *RingBuffer) NewReader() io.Reader {
+	return bytes.NewReader(r.ReadAll())
+}
+
+// ReadFromEnd returns a copy of the last n bytes retained by the ring
+// and the offset just past the returned bytes, which can be passed to
+// ReadOffset to continue reading newly written bytes. If n is larger
+// than the number of bytes retained, all retained bytes are returned.
+func (r *RingBuffer) ReadFromEnd(n int) ([]byte, int) {
+	if n < 0 {
+		n = 0
+	}
+	if n > r.Len() {
+		n = r.Len()
+	}
+	data := make([]byte, n)
+	r.copyAt(data, r.Offset()-n)
+	return data, r.Offset()
+}
+
+// Equal returns true if the other ring buffer has the same logical
+// contents, offset and configured capacity as this ring buffer. Unlike comparing
+// Bytes() the physical position of the bytes within the ring and the number of
+// bytes allocated so far are ignored.
+func (r *RingBuffer) Equal(other *RingBuffer) bool {
+	if r.Offset() != other.Offset() || r.capacity != other.capacity {
+		return false
+	}
+	return bytes.Equal(r.ReadAll(), other.ReadAll())
+}
+
+// CopyTo copies the bytes written after the provided offset into dst, returning the number
+// of bytes copied and the offset just past the copied bytes, which should be provided to the
+// next call to continue reading. If dst is too small to hold all the bytes, the remaining
+// bytes are copied by the next call. If the offset has been discarded, copying begins with
+// the oldest retained byte. Unlike ReadOffset, CopyTo never allocates, which makes it the
+// preferred primitive for reader loops which reuse a buffer.
+func (r *RingBuffer) CopyTo(dst []byte, offset int) (int, int) {
+	if start := r.start(); offset < start {
+		offset = start
+	}
+	total := r.Offset()
+	if offset >= total {
+		return 0, total
+	}
+
+	n := total - offset
+	if n > len(dst) {
+		n = len(dst)
+	}
+	r.copyAt(dst[:n], offset)
+	return n, offset + n
+}
+
+// ReadOffsetTo writes the bytes written after the provided offset directly to w without
+// an intermediate copy, using two calls to w.Write if the bytes wrap around the end of the
+// ring. Returns the number of bytes written and the offset just past the written bytes,
+// which should be provided to the next call to continue reading. Like ReadOffset, if the
+// offset has been discarded, writing begins with the oldest retained byte.
+func (r *RingBuffer) ReadOffsetTo(offset int, w io.Writer) (int, int, error) {
+	if start := r.start(); offset < start {
+		offset = start
+	}
+	total := r.Offset()
+	if offset >= total {
+		return 0, total, nil
+	}
+
+	pos := offset % r.capacity
+	end := pos + total - offset
+	if end <= r.capacity {
+		n, err := w.Write(r.buffer[pos:end])
+		return n, offset + n, err
+	}
+
+	n, err := w.Write(r.buffer[pos:r.capacity])
+	if err != nil {
+		return n, offset + n, err
+	}
+	m, err := w.Write(r.buffer[:end-r.capacity])
+	return n + m, offset + n + m, err
+}
+
+// ReadAt implements io.ReaderAt using logical offsets, such that off is the offset of the byte
+// in the order written rather than the position in the ring. Returns io.EOF if fewer than
+// len(p) bytes are available after off, and ErrDataDiscarded if off is no longer retained.
+func (r *RingBuffer) ReadAt(p []byte, off int64) (int, error) {
+	offset := int(off)
+	if offset < r.start() {
+		return 0, ErrDataDiscarded
+	}
+	total := r.Offset()
+	if offset >= total {
+		return 0, io.EOF
+	}
+
+	n := total - offset
+	if n > len(p) {
+		n = len(p)
+	}
+	r.copyAt(p[:n], offset)
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// WriteAt overwrites the retained bytes beginning at the provided logical offset, such that out
+// of order chunks with known positions can be reassembled within the ring. Returns ErrDataDiscarded
+// if off is no longer retained, and ErrOutOfRange if the bytes would extend past the bytes already
+// written, WriteAt never extends the ring, use Write to append.
+func (r *RingBuffer) WriteAt(p []byte, off int) error {
+	if off < r.start() {
+		return ErrDataDiscarded
+	}
+	if off+len(p) > r.Offset() {
+		return ErrOutOfRange
+	}
+
+	pos := off % r.capacity
+	end := len(r.buffer)
+	if end > r.capacity {
+		end = r.capacity
+	}
+	// Copy bytes from the position until the end of the ring, then
+	// the remainder to the beginning of the ring.
+	n := copy(r.buffer[pos:end], p)
+	copy(r.buffer, p[n:])
+	return nil
+}
+
+// maxStringLen is the maximum number of bytes returned by String
+const maxStringLen = 1024
+
+// String returns the retained bytes in the order they were written. If more than
+// maxStringLen bytes are retained, only the most recent bytes are returned
+// preceded by an ellipsis.
+func (r *RingBuffer) String() string {
+	if r.Len() > maxStringLen {
+		data, _ := r.ReadFromEnd(maxStringLen)
+		return "..." + string(data)
+	}
+	return string(r.ReadAll())
+}
+
+// copyAt copies len(dst) bytes starting at the provided logical
+// offset into dst. The caller must ensure the requested bytes
+// are retained by the ring.
+func (r *RingBuffer) copyAt(dst []byte, offset int) {
+	pos := offset % r.capacity
+	end := len(r.buffer)
+	if end > r.capacity {
+		end = r.capacity
+	}
+	// Copy bytes from the position until the end of the ring, then
+	// the remainder from the beginning of the ring.
+	n := copy(dst, r.buffer[pos:end])
+	copy(dst[n:], r.buffer[:end])
+}
@@ -1,11 +1,16 @@
 package steve_test
 
 import (
+	"bufio"
+	"bytes"
 	"crypto/rand"
+	"fmt"
+	"io"
 	"math/big"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/thrawn01/steve"
 )
 
@@ -141,12 +146,487 @@ func TestRingBufferGrowEffectively(t *testing.T) {
 	assert.Equal(t, steve.AllocSize*10, rb.Capacity())
 }
 
+func TestRingBufferReadRange(t *testing.T) {
+	rb := steve.NewRingBuffer(10)
+	rb.Write([]byte("Hello"))
+
+	// Fully retained window
+	data, err := rb.ReadRange(1, 4)
+	require.NoError(t, err)
+	assert.Equal(t, "ell", string(data))
+
+	// Empty window
+	data, err = rb.ReadRange(5, 5)
+	require.NoError(t, err)
+	assert.Equal(t, "", string(data))
+
+	// Wrap around the end of the ring
+	rb.Write([]byte(" World"))
+	data, err = rb.ReadRange(5, 11)
+	require.NoError(t, err)
+	assert.Equal(t, " World", string(data))
+
+	// The first byte 'H' has been overwritten
+	_, err = rb.ReadRange(0, 5)
+	assert.ErrorIs(t, err, steve.ErrDataDiscarded)
+
+	// Oldest retained byte is still readable
+	data, err = rb.ReadRange(1, 5)
+	require.NoError(t, err)
+	assert.Equal(t, "ello", string(data))
+
+	// Out of range windows
+	_, err = rb.ReadRange(5, 12)
+	assert.ErrorIs(t, err, steve.ErrOutOfRange)
+	_, err = rb.ReadRange(6, 5)
+	assert.ErrorIs(t, err, steve.ErrOutOfRange)
+	_, err = rb.ReadRange(-1, 5)
+	assert.ErrorIs(t, err, steve.ErrOutOfRange)
+}
+
+func TestRingBufferEqual(t *testing.T) {
+	a := steve.NewRingBuffer(10)
+	b := steve.NewRingBuffer(10)
+
+	// Written differently, but with identical logical contents
+	a.Write([]byte("Hello World"))
+	b.Write([]byte("Hello"))
+	b.Write([]byte(" World"))
+	assert.True(t, a.Equal(b))
+	assert.True(t, b.Equal(a))
+	assert.Equal(t, "ello World", string(a.ReadAll()))
+
+	// Same logical contents, but a different offset
+	c := steve.NewRingBuffer(10)
+	c.Write([]byte("ello World"))
+	assert.Equal(t, a.ReadAll(), c.ReadAll())
+	assert.False(t, a.Equal(c))
+
+	// Differing contents
+	b.Write([]byte("!"))
+	a.Write([]byte("?"))
+	assert.False(t, a.Equal(b))
+
+	// A lazy ring which was never written equals an empty allocated ring of the same capacity
+	lazy := steve.NewRingBufferLazy(10_000)
+	empty := steve.NewRingBuffer(10_000)
+	assert.True(t, lazy.Equal(empty))
+	assert.True(t, empty.Equal(lazy))
+	assert.False(t, lazy.Equal(steve.NewRingBuffer(20_000)))
+}
+
+func TestRingBufferReadFromEnd(t *testing.T) {
+	rb := steve.NewRingBuffer(10)
+	rb.Write([]byte("Hello"))
+
+	data, offset := rb.ReadFromEnd(3)
+	assert.Equal(t, "llo", string(data))
+	assert.Equal(t, 5, offset)
+
+	// Read across the wrap boundary
+	rb.Write([]byte(" World"))
+	data, offset = rb.ReadFromEnd(7)
+	assert.Equal(t, "o World", string(data))
+	assert.Equal(t, 11, offset)
+
+	// Asking for more than is retained returns everything retained
+	data, offset = rb.ReadFromEnd(100)
+	assert.Equal(t, "ello World", string(data))
+	assert.Equal(t, 11, offset)
+
+	data, offset = rb.ReadFromEnd(0)
+	assert.Equal(t, "", string(data))
+	assert.Equal(t, 11, offset)
+
+	// The returned offset can be used to follow new writes
+	rb.Write([]byte("!"))
+	data, offset = rb.ReadOffset(offset)
+	assert.Equal(t, "!", string(data))
+	assert.Equal(t, 12, offset)
+}
+
+func TestRingBufferConcurrentOffset(t *testing.T) {
+	rb := steve.NewRingBuffer(100)
+	chunk := []byte("0123456789")
+	const writes = 10_000
+
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < writes; i++ {
+			rb.Write(chunk)
+		}
+		close(done)
+	}()
+
+	// Offset should only ever report complete writes and never go backwards
+	var last int
+	for {
+		offset := rb.Offset()
+		assert.GreaterOrEqual(t, offset, last)
+		assert.Equal(t, 0, offset%len(chunk))
+		last = offset
+
+		select {
+		case <-done:
+			assert.Equal(t, writes*len(chunk), rb.Offset())
+			return
+		default:
+		}
+	}
+}
+
+func TestRingBufferGrow(t *testing.T) {
+	rb := steve.NewRingBuffer(steve.AllocSize * 10)
+	assert.Equal(t, steve.AllocSize, rb.Capacity())
+
+	rb.Write([]byte("Hello, World"))
+
+	// Pre-grow ahead of a large write
+	rb.Grow(steve.AllocSize * 3)
+	grown := rb.Capacity()
+	assert.GreaterOrEqual(t, grown, steve.AllocSize*3+12)
+
+	// Writing the amount grown for should not reallocate
+	rb.Write(randomAlpha(steve.AllocSize * 3))
+	assert.Equal(t, grown, rb.Capacity())
+
+	// Growing for less than is available should not shrink the buffer
+	rb.Grow(1)
+	assert.Equal(t, grown, rb.Capacity())
+
+	// Contents should survive the growth
+	data, _ := rb.ReadOffset(0)
+	assert.Equal(t, "Hello, World", string(data[:12]))
+
+	// Growing beyond the capacity of the ring only allocates the capacity
+	rb.Grow(steve.AllocSize * 100)
+	assert.Equal(t, steve.AllocSize*10, rb.Capacity())
+}
+
+func TestRingBufferGrowNeverExceedsCapacity(t *testing.T) {
+	rb := steve.NewRingBuffer(5120)
+
+	rb.Write(randomAlpha(2000))
+	rb.Write(randomAlpha(2000))
+	assert.Equal(t, 4000, rb.Capacity())
+
+	// A write which grows beyond the capacity of the ring should
+	// only allocate the capacity of the ring.
+	rb.Write(randomAlpha(1500))
+	assert.Equal(t, 5120, rb.Capacity())
+	assert.Len(t, rb.Bytes(), 5120)
+}
+
+func TestRingBufferTruncate(t *testing.T) {
+	rb := steve.NewRingBuffer(20)
+	rb.Write([]byte("Hello World"))
+	assert.Equal(t, 11, rb.Len())
+
+	rb.Truncate(6)
+	assert.Equal(t, 5, rb.Len())
+	assert.Equal(t, 11, rb.Offset())
+
+	// Reads below the floor return the retained window
+	data, offset := rb.ReadOffset(0)
+	assert.Equal(t, "World", string(data))
+	assert.Equal(t, 11, offset)
+	data, _ = rb.ReadOffset(8)
+	assert.Equal(t, "rld", string(data))
+	assert.Equal(t, "World", string(rb.ReadAll()))
+
+	_, err := rb.ReadRange(0, 11)
+	assert.ErrorIs(t, err, steve.ErrDataDiscarded)
+
+	// New writes are retained after the floor
+	rb.Write([]byte("!"))
+	assert.Equal(t, 6, rb.Len())
+	data, _ = rb.ReadOffset(0)
+	assert.Equal(t, "World!", string(data))
+
+	// Truncating more than is retained discards everything
+	rb.Truncate(100)
+	assert.Equal(t, 0, rb.Len())
+	data, offset = rb.ReadOffset(0)
+	assert.Equal(t, "", string(data))
+	assert.Equal(t, 12, offset)
+
+	// Once the ring wraps past the floor, the capacity bounds retention
+	rb.Write([]byte("0123456789012345678901234"))
+	assert.Equal(t, 20, rb.Len())
+}
+
+func TestRingBufferDiscardOldest(t *testing.T) {
+	rb := steve.NewRingBuffer(20)
+	rb.Write([]byte("Hello World"))
+
+	assert.Equal(t, 6, rb.DiscardOldest(6))
+	assert.Equal(t, 5, rb.Len())
+	assert.Equal(t, "World", string(rb.ReadAll()))
+
+	// Discarding more than is retained returns only what was discarded
+	assert.Equal(t, 5, rb.DiscardOldest(100))
+	assert.Equal(t, 0, rb.Len())
+	assert.Equal(t, 11, rb.Offset())
+	assert.Equal(t, 0, rb.DiscardOldest(1))
+	assert.Equal(t, 0, rb.DiscardOldest(-1))
+
+	rb.Write([]byte("!"))
+	assert.Equal(t, 1, rb.Len())
+	data, offset := rb.ReadOffset(0)
+	assert.Equal(t, "!", string(data))
+	assert.Equal(t, 12, offset)
+}
+
 func TestEmptyBuffer(t *testing.T) {
-	assert.Panics(t, func() {
+	assert.PanicsWithValue(t, "NewRingBuffer: A capacity of zero is not allowed", func() {
 		steve.NewRingBuffer(0)
 	})
 }
 
+func TestNegativeCapacity(t *testing.T) {
+	assert.PanicsWithValue(t, "NewRingBuffer: A negative capacity of -1 is not allowed", func() {
+		steve.NewRingBuffer(-1)
+	})
+	assert.PanicsWithValue(t, "NewRingBuffer: A negative capacity of -1 is not allowed", func() {
+		steve.NewRingBufferStore(-1)
+	})
+}
+
+func TestRingBufferNewReader(t *testing.T) {
+	rb := steve.NewRingBuffer(100)
+	rb.Write([]byte("line: 0\nline: 1\nline: 2\n"))
+
+	r := rb.NewReader()
+	rb.Write([]byte("line: 3\n"))
+
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	require.NoError(t, scanner.Err())
+	// Only the bytes retained when the reader was created are returned
+	assert.Equal(t, []string{"line: 0", "line: 1", "line: 2"}, lines)
+}
+
+func TestRingBufferCopyTo(t *testing.T) {
+	rb := steve.NewRingBuffer(100)
+	// Write enough to wrap the ring several times, with writes which don't align with the capacity
+	for i := 0; i < 10; i++ {
+		rb.Write(randomAlpha(37))
+	}
+
+	buf := make([]byte, 30)
+	for _, offset := range []int{0, 269, 270, 271, 300, 369, 370, 400} {
+		want, wantNext := rb.ReadOffset(offset)
+
+		// Copy using a buffer smaller than the ring, such that reads cross the wrap boundary
+		var got []byte
+		next := offset
+		for {
+			n, nextOffset := rb.CopyTo(buf, next)
+			got = append(got, buf[:n]...)
+			next = nextOffset
+			if n == 0 {
+				break
+			}
+		}
+		assert.Equal(t, string(want), string(got), "offset %d", offset)
+		assert.Equal(t, wantNext, next, "offset %d", offset)
+	}
+
+	allocs := testing.AllocsPerRun(100, func() {
+		_, _ = rb.CopyTo(buf, 300)
+	})
+	assert.Equal(t, float64(0), allocs)
+}
+
+func TestRingBufferReadOffsetExactCapacity(t *testing.T) {
+	for _, capacity := range []int{10, steve.AllocSize, steve.AllocSize * 3} {
+		rb := steve.NewRingBuffer(capacity)
+		data := randomAlpha(capacity)
+
+		// Until the ring is full, the Write position is never the read position
+		rb.Write(data[:capacity-1])
+		out, offset := rb.ReadOffset(0)
+		assert.Equal(t, string(data[:capacity-1]), string(out))
+		assert.Equal(t, capacity-1, offset)
+
+		// Writing exactly the capacity wraps the Write position back to the start
+		rb.Write(data[capacity-1:])
+		out, offset = rb.ReadOffset(0)
+		assert.Equal(t, string(data), string(out))
+		assert.Equal(t, capacity, offset)
+
+		out, offset = rb.ReadOffset(capacity - 1)
+		assert.Equal(t, string(data[capacity-1:]), string(out))
+		assert.Equal(t, capacity, offset)
+	}
+}
+
+func TestRingBufferAllocSizeCapacity(t *testing.T) {
+	// A capacity of exactly AllocSize is fully allocated up front and never grows, it should
+	// read the same as rings which grow towards their capacity.
+	for name, newRing := range map[string]func(int) *steve.RingBuffer{
+		"NewRingBuffer":     steve.NewRingBuffer,
+		"NewRingBufferLazy": steve.NewRingBufferLazy,
+	} {
+		for _, capacity := range []int{steve.AllocSize - 1, steve.AllocSize, steve.AllocSize + 1, steve.AllocSize * 2} {
+			t.Run(fmt.Sprintf("%s/%d", name, capacity), func(t *testing.T) {
+				rb := newRing(capacity)
+				var written []byte
+				var last int
+
+				// Write sizes chosen to land on, just before and just after the allocation boundary
+				for _, size := range []int{1, steve.AllocSize - 2, 1, steve.AllocSize, 7, steve.AllocSize + 1, 3} {
+					data := randomAlpha(size)
+					rb.Write(data)
+					written = append(written, data...)
+					total := len(written)
+					oldest := total - capacity
+					if oldest < 0 {
+						oldest = 0
+					}
+
+					for _, offset := range []int{0, last, oldest, oldest + 1, total - 1, total} {
+						from := offset
+						if from < oldest {
+							from = oldest
+						}
+						if from > total {
+							from = total
+						}
+						out, next := rb.ReadOffset(offset)
+						assert.Equal(t, string(written[from:]), string(out), "offset %d of %d", offset, total)
+						assert.Equal(t, total, next)
+
+						var buf bytes.Buffer
+						_, next, err := rb.ReadOffsetTo(offset, &buf)
+						require.NoError(t, err)
+						assert.Equal(t, string(written[from:]), buf.String())
+						assert.Equal(t, total, next)
+					}
+					assert.Equal(t, string(written[oldest:]), string(rb.ReadAll()))
+					assert.LessOrEqual(t, rb.Capacity(), capacity)
+					last = total
+				}
+				assert.Equal(t, capacity, rb.Capacity())
+				if capacity <= steve.AllocSize {
+					assert.Equal(t, 0, rb.Stats().Grows)
+				}
+			})
+		}
+	}
+}
+
+func TestRingBufferReadAt(t *testing.T) {
+	rb := steve.NewRingBuffer(10)
+	rb.Write([]byte("0123456789abcdef"))
+
+	// A range which crosses the wrap boundary
+	buf := make([]byte, 6)
+	n, err := rb.ReadAt(buf, 8)
+	require.NoError(t, err)
+	assert.Equal(t, "89abcd", string(buf[:n]))
+
+	// A range which extends past the end
+	n, err = rb.ReadAt(buf, 13)
+	assert.ErrorIs(t, err, io.EOF)
+	assert.Equal(t, "def", string(buf[:n]))
+	_, err = rb.ReadAt(buf, 16)
+	assert.ErrorIs(t, err, io.EOF)
+
+	// An offset which is no longer retained
+	_, err = rb.ReadAt(buf, 5)
+	assert.ErrorIs(t, err, steve.ErrDataDiscarded)
+
+	// Works with code which expects an io.ReaderAt
+	out, err := io.ReadAll(io.NewSectionReader(rb, 10, 4))
+	require.NoError(t, err)
+	assert.Equal(t, "abcd", string(out))
+}
+
+func TestRingBufferWriteAt(t *testing.T) {
+	rb := steve.NewRingBuffer(10)
+	rb.Write([]byte("0123456789abcdef"))
+
+	// Overwrite a range which crosses the wrap boundary
+	require.NoError(t, rb.WriteAt([]byte("XYZW"), 8))
+	assert.Equal(t, "67XYZWcdef", string(rb.ReadAll()))
+	assert.Equal(t, 16, rb.Offset())
+
+	// Offsets outside the retained range
+	assert.ErrorIs(t, rb.WriteAt([]byte("X"), 5), steve.ErrDataDiscarded)
+	assert.ErrorIs(t, rb.WriteAt([]byte("XY"), 15), steve.ErrOutOfRange)
+	assert.Equal(t, "67XYZWcdef", string(rb.ReadAll()))
+}
+
+func TestRingBufferStats(t *testing.T) {
+	rb := steve.NewRingBuffer(steve.AllocSize * 4)
+	assert.Equal(t, steve.RingStats{Capacity: steve.AllocSize * 4}, rb.Stats())
+
+	// Writes which fit in the initial allocation do not grow the ring
+	rb.Write(randomAlpha(100))
+	assert.Equal(t, 0, rb.Stats().Grows)
+
+	// Each write larger than the allocation grows the ring until it reaches the capacity
+	rb.Write(randomAlpha(600))
+	assert.Equal(t, 1, rb.Stats().Grows)
+	rb.Write(randomAlpha(1_000))
+	assert.Equal(t, 2, rb.Stats().Grows)
+	rb.Write(randomAlpha(1_000))
+	assert.Equal(t, 3, rb.Stats().Grows)
+
+	// Once at capacity the ring never grows, and discards the oldest bytes
+	rb.Write(randomAlpha(1_000))
+	assert.Equal(t, steve.RingStats{
+		Capacity:  steve.AllocSize * 4,
+		Len:       steve.AllocSize * 4,
+		Offset:    3_700,
+		Discarded: 3_700 - steve.AllocSize*4,
+		Grows:     3,
+	}, rb.Stats())
+}
+
+func TestRingBufferString(t *testing.T) {
+	rb := steve.NewRingBuffer(10)
+	rb.Write([]byte("Hello, World"))
+
+	// The ring has wrapped, but the string is in the order written
+	assert.Equal(t, "llo, World", rb.String())
+	assert.Equal(t, "llo, World", fmt.Sprintf("%s", rb))
+
+	// Large buffers are capped to the most recent bytes
+	rb = steve.NewRingBuffer(5000)
+	data := randomAlpha(3000)
+	rb.Write(data)
+	assert.Equal(t, "..."+string(data[3000-1024:]), rb.String())
+}
+
+func TestRingBufferReadOffsetNoAlloc(t *testing.T) {
+	rb := steve.NewRingBuffer(100)
+	rb.Write([]byte("Hello"))
+
+	allocs := testing.AllocsPerRun(100, func() {
+		data, _ := rb.ReadOffset(5)
+		if len(data) != 0 {
+			t.Fatal("expected no new data")
+		}
+	})
+	assert.Equal(t, float64(0), allocs)
+}
+
+func BenchmarkRingBufferReadOffsetNoData(b *testing.B) {
+	rb := steve.NewRingBuffer(100)
+	rb.Write([]byte("Hello"))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		_, _ = rb.ReadOffset(5)
+	}
+}
+
 //func randomAlpha(size int) []byte {
 //	buf := make([]byte, size)
 //	unicodeRanges := fuzz.UnicodeRanges{
@@ -176,3 +656,199 @@ func randomAlpha(size int) []byte {
 
 	return randomBytes
 }
+
+func TestRingBufferReadOffsetNoAlias(t *testing.T) {
+	rb := steve.NewRingBuffer(10)
+
+	// Before the ring wraps
+	rb.Write([]byte("012345"))
+	straight, _ := rb.ReadOffset(2)
+	assert.Equal(t, "2345", string(straight))
+
+	// Reading across the end of the ring
+	rb.Write([]byte("6789ab"))
+	wrapped, _ := rb.ReadOffset(8)
+	assert.Equal(t, "89ab", string(wrapped))
+
+	// Reading a full ring
+	full, _ := rb.ReadOffset(0)
+	assert.Equal(t, "23456789ab", string(full))
+
+	// Continuous writes overwrite every position in the ring many times
+	for i := 0; i < 1_000; i++ {
+		rb.Write([]byte(fmt.Sprintf("%03d", i)))
+	}
+
+	assert.Equal(t, "2345", string(straight))
+	assert.Equal(t, "89ab", string(wrapped))
+	assert.Equal(t, "23456789ab", string(full))
+}
+
+func TestNewRingBufferLazy(t *testing.T) {
+	rb := steve.NewRingBufferLazy(2048)
+	assert.Equal(t, 0, rb.Capacity())
+	assert.Equal(t, 0, rb.Len())
+	data, offset := rb.ReadOffset(0)
+	assert.Equal(t, "", string(data))
+	assert.Equal(t, 0, offset)
+
+	// The first write allocates the same initial size as NewRingBuffer
+	rb.Write([]byte("Hello"))
+	assert.Equal(t, steve.AllocSize, rb.Capacity())
+	assert.Equal(t, 0, rb.Stats().Grows)
+	assert.Equal(t, "Hello", string(rb.ReadAll()))
+
+	// A small capacity allocates only the capacity
+	rb = steve.NewRingBufferLazy(10)
+	assert.Equal(t, 0, rb.Capacity())
+	rb.Write([]byte("Hello, World"))
+	assert.Equal(t, 10, rb.Capacity())
+	assert.Equal(t, "llo, World", string(rb.ReadAll()))
+
+	// A first write larger than the initial size allocates enough for the write
+	rb = steve.NewRingBufferLazy(4096)
+	rb.Write(make([]byte, 1000))
+	assert.Equal(t, 2000, rb.Capacity())
+	assert.Equal(t, 1000, rb.Len())
+
+	assert.PanicsWithValue(t, "NewRingBufferLazy: A capacity of zero is not allowed", func() {
+		steve.NewRingBufferLazy(0)
+	})
+}
+
+func TestRingBufferReadOffsetChecked(t *testing.T) {
+	rb := steve.NewRingBuffer(10)
+	rb.Write([]byte("0123456789"))
+
+	// Nothing has been discarded while the ring is exactly full
+	data, offset, gap := rb.ReadOffsetChecked(0)
+	assert.Equal(t, "0123456789", string(data))
+	assert.Equal(t, 10, offset)
+	assert.False(t, gap)
+
+	// Once the ring wraps, reading from a discarded offset reports the gap
+	rb.Write([]byte("ab"))
+	data, _, gap = rb.ReadOffsetChecked(1)
+	assert.Equal(t, "23456789ab", string(data))
+	assert.True(t, gap)
+	data, _, gap = rb.ReadOffsetChecked(2)
+	assert.Equal(t, "23456789ab", string(data))
+	assert.False(t, gap)
+
+	// Bytes discarded by Truncate are also a gap
+	rb.Truncate(3)
+	data, _, gap = rb.ReadOffsetChecked(4)
+	assert.Equal(t, "56789ab", string(data))
+	assert.True(t, gap)
+	data, _, gap = rb.ReadOffsetChecked(5)
+	assert.Equal(t, "56789ab", string(data))
+	assert.False(t, gap)
+
+	data, offset, gap = rb.ReadOffsetChecked(12)
+	assert.Equal(t, "", string(data))
+	assert.Equal(t, 12, offset)
+	assert.False(t, gap)
+}
+
+func TestRingBufferReadOffsetSkipped(t *testing.T) {
+	rb := steve.NewRingBuffer(10)
+	rb.Write([]byte("0123456789ab"))
+
+	// The ring retains offsets 2 through 12
+	data, offset, skipped := rb.ReadOffsetSkipped(2)
+	assert.Equal(t, "23456789ab", string(data))
+	assert.Equal(t, 12, offset)
+	assert.Equal(t, 0, skipped)
+
+	for _, k := range []int{0, 1} {
+		data, _, skipped = rb.ReadOffsetSkipped(k)
+		assert.Equal(t, "23456789ab", string(data))
+		assert.Equal(t, 2-k, skipped)
+	}
+
+	// Truncate raises the floor
+	rb.Truncate(3)
+	data, _, skipped = rb.ReadOffsetSkipped(1)
+	assert.Equal(t, "56789ab", string(data))
+	assert.Equal(t, 4, skipped)
+	_, _, skipped = rb.ReadOffsetSkipped(5)
+	assert.Equal(t, 0, skipped)
+
+	// Offsets past the end skip nothing
+	data, offset, skipped = rb.ReadOffsetSkipped(20)
+	assert.Equal(t, "", string(data))
+	assert.Equal(t, 12, offset)
+	assert.Equal(t, 0, skipped)
+}
+
+func TestRingBufferResetCapacity(t *testing.T) {
+	rb := steve.NewRingBuffer(10)
+	rb.Write([]byte("Hello, World"))
+	rb.Truncate(2)
+
+	for _, capacity := range []int{10, steve.AllocSize * 4, 20} {
+		rb.ResetCapacity(capacity)
+		fresh := steve.NewRingBuffer(capacity)
+		assert.Equal(t, fresh.Stats(), rb.Stats())
+		assert.Equal(t, fresh.Capacity(), rb.Capacity())
+		data, offset := rb.ReadOffset(0)
+		assert.Equal(t, "", string(data))
+		assert.Equal(t, 0, offset)
+
+		// Growth towards the capacity matches a freshly constructed ring
+		for _, size := range []int{5, steve.AllocSize, steve.AllocSize * 2, 3} {
+			data := randomAlpha(size)
+			rb.Write(data)
+			fresh.Write(data)
+			assert.Equal(t, fresh.Stats(), rb.Stats())
+			assert.Equal(t, fresh.Capacity(), rb.Capacity())
+			assert.True(t, fresh.Equal(rb))
+		}
+	}
+
+	rb.Write([]byte("Hello"))
+	rb.Reset()
+	assert.Equal(t, steve.NewRingBuffer(20).Stats(), rb.Stats())
+
+	assert.Panics(t, func() { rb.ResetCapacity(0) })
+	assert.Panics(t, func() { rb.ResetCapacity(-1) })
+}
+
+// countingWriter counts the calls to Write
+type countingWriter struct {
+	bytes.Buffer
+	writes int
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	c.writes++
+	return c.Buffer.Write(p)
+}
+
+func TestRingBufferReadOffsetTo(t *testing.T) {
+	rb := steve.NewRingBuffer(10)
+
+	// Parity with ReadOffset from every offset, before and after the ring wraps
+	for _, chunk := range []string{"0123", "4567", "89ab", "cdefg"} {
+		rb.Write([]byte(chunk))
+		for offset := 0; offset <= rb.Offset(); offset++ {
+			var w countingWriter
+			n, next, err := rb.ReadOffsetTo(offset, &w)
+			require.NoError(t, err)
+
+			expected, expectedNext := rb.ReadOffset(offset)
+			assert.Equal(t, string(expected), w.String(), "offset %d", offset)
+			assert.Equal(t, len(expected), n)
+			assert.Equal(t, expectedNext, next)
+			assert.LessOrEqual(t, w.writes, 2)
+		}
+	}
+
+	// Write errors are returned along with the bytes written
+	_, w := io.Pipe()
+	require.NoError(t, w.Close())
+	n, next, err := rb.ReadOffsetTo(15, w)
+	assert.ErrorIs(t, err, io.ErrClosedPipe)
+	assert.Equal(t, 0, n)
+	assert.Equal(t, 15, next)
+}
@@ -3,9 +3,13 @@ package steve
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
 	"errors"
 	"fmt"
+	"hash"
 	"io"
+	"regexp"
+	"sort"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -16,197 +20,2230 @@ import (
 )
 
 var (
-	ErrJobNotFound   = errors.New("no such job found")
+	ErrJobNotFound = errors.New("no such job found")
+	// ErrJobNotRunning is returned by every operation which requires a running job, such as
+	// Stop, TryStop, StopGraceful, Flush, Pause and Resume, when the job is not running.
 	ErrJobNotRunning = errors.New("job not running")
+	ErrJobExists     = errors.New("job already exists")
+	ErrNilJob        = errors.New("job is nil")
+	ErrJobRunning    = errors.New("job is running")
+	// ErrAlreadyRunning is returned by a runner created with WithInstanceDetection
+	// when the same Job instance is already running.
+	ErrAlreadyRunning = errors.New("job instance is already running")
+	// ErrCapacityExceeded is returned by a runner created with NewJobRunnerBounded
+	// when the runner already holds the maximum number of jobs.
+	ErrCapacityExceeded = errors.New("runner capacity exceeded")
+	// ErrJobPanicked is wrapped by the error reported via Status.Err when the job panics, either
+	// from Start or from a callback provided via RunOptions such as Transform.
+	ErrJobPanicked    = errors.New("job panicked")
+	ErrNotReady       = errors.New("job stopped before it was ready")
+	ErrWouldBlock     = errors.New("operation would block")
+	ErrRunnerClosed   = errors.New("runner is closed")
+	ErrReaderNotFound = errors.New("no such reader found")
+	ErrReaderClosed   = errors.New("reader was closed by CloseReader")
+	ErrReaderTimeout  = errors.New("reader did not accept the backlog before the timeout")
+	// ErrNoInput is returned by Input when the job does not implement InputStarter or does not accept input
+	ErrNoInput = errors.New("job does not accept input")
+	// ErrStartTimeout is returned when the job does not start within RunOptions.StartTimeout
+	ErrStartTimeout = errors.New("job did not start before the timeout")
+	// ErrGroupNotFound is returned by NewGroupReader when no job in the group is held by the runner
+	ErrGroupNotFound = errors.New("no such group found")
+	// ErrOutputDiscarded is returned when reading the output of a job which was discarded
+	// once the job stopped, see RunOptions.FreeOnStopIfUnread.
+	ErrOutputDiscarded = errors.New("job output was discarded")
 )
 
+// OverflowPolicy determines what happens to output read from a job when the runner
+// can not store the output as fast as the job writes it, see WithOutputBuffer.
+type OverflowPolicy int
+
+const (
+	// OverflowBlock blocks the job from writing until the buffered output is stored
+	OverflowBlock OverflowPolicy = iota
+	// OverflowDropNewest discards new output while the buffer is full
+	OverflowDropNewest
+	// OverflowDropOldest discards the oldest buffered output to make room for new output
+	OverflowDropOldest
+)
+
+// RestartSeparator is written to the output of a job restarted by Runner.Restart with
+// RestartOptions.PreserveOutput, separating the output of the previous run from the next.
+const RestartSeparator = "--- restart ---\n"
+
+// DefaultSweepInterval is how often the runner removes stopped jobs which have
+// exceeded RunOptions.RetainAfterStop, unless configured by WithSweepInterval.
+const DefaultSweepInterval = time.Second
+
+// tryStopWait is how long TryStop waits for the job to stop before returning ErrWouldBlock
+const tryStopWait = time.Millisecond * 10
+
 type jobIO struct {
-	br      syncutil.Broadcaster
-	writer  io.WriteCloser
-	buffer  bytes.Buffer
-	mutex   sync.Mutex
+	ctx    context.Context
+	cancel context.CancelFunc
+	br     syncutil.Broadcaster
+	writer *io.PipeWriter
+	store  OutputStore
+	// mutex guards the store, and is shared with the job which preserves the output on Restart
+	mutex   *sync.Mutex
 	started time.Time
 	stopped time.Time
-	id      ID
-	running int64
+	// firstOutput is the time the job first wrote output
+	firstOutput time.Time
+	id          ID
+	running     int64
+	stopping    bool
+	paused      bool
+	// pending is true until a job with a barrier or a queued job is started
+	pending bool
+	// canceled is true if the job was stopped while pending
+	canceled bool
+	// starting is not nil while Start is called for a job which was pending, and is closed once
+	// Start returns, such that a job stopped while it is starting is stopped once Start returns.
+	starting chan struct{}
+	// input is written to by Runner.Input and read by a job which implements InputStarter
+	input       *io.PipeWriter
+	inputReader *io.PipeReader
+	// clock is the clock of the runner
+	clock Clock
+	// slot is true while the job holds a slot of a runner created with WithMaxConcurrent
+	slot    bool
+	readers int
+	// read is true once a reader has attached to the job, discarded is true
+	// if the output was discarded once the job stopped unread
+	read      bool
+	discarded bool
+	// handedOff is true once the store was handed to the job which replaced this job on Restart
+	handedOff bool
+	// counted is the number of bytes of the store counted toward runner.usage
+	counted int
+	// timedOut is true if the job was stopped by the IdleTimeout, reason is why the
+	// output of the job ended once the job has stopped
+	timedOut bool
+	reason   EndReason
+	// detached is closed once the last attached reader detaches
+	detached chan struct{}
+	// handles maps the ID of each attached reader to a function which disconnects the reader
+	handles map[string]func()
+	// isReady is closed once the ReadyWhen predicate is satisfied, probed and
+	// probeLines are only accessed by the monitor go routine.
+	isReady    chan struct{}
+	probed     bool
+	probeLines lineBuffer
+	// collapse, timestamps, logLines and mirrors are only accessed by the monitor go routine
+	collapse   collapser
+	timestamps timestamper
+	logLines   lineBuffer
+	mirrors    []io.Writer
+	// group if not nil, is the group the job writes output to, see RunOptions.Group. groupLines
+	// is only accessed by the monitor go routine.
+	group      *group
+	groupLines lineBuffer
+	// done is closed once the monitor go routine has exited
+	done     chan struct{}
+	halted   chan struct{}
+	attempts int
+	// err is the error the job failed with, once the job will not be restarted
+	err     error
+	written int
+	// dropped is the number of bytes discarded by the overflow policy, accessed atomically
+	dropped int64
+	// used orders the jobs by when they were last looked up, used to evict the least recently
+	// used job among jobs of the same priority. Accessed atomically.
+	used int64
+	// offset is the offset in the store just past the last byte stored
+	offset int
+	// announced is true once EventStarted was sent, until then the events of the job are deferred
+	// such that no events are sent for a job which fails to start. Guarded by eventMutex.
+	announced  bool
+	deferred   []EventType
+	eventMutex sync.Mutex
+	// hash is the SHA-256 digest of every byte stored, including bytes the store has since discarded
+	hash    hash.Hash
+	markers map[string]int
 	job     Job
+	opts    RunOptions
+	ready   chan struct{}
+}
+
+type runner struct {
+	jobs     *collections.LRUCache
+	capacity int
+	wg       syncutil.WaitGroup
+	mutex    sync.Mutex
+	newStore func() OutputStore
+	keys     map[string]ID
+	keyMutex sync.Mutex
+	// instances if not nil, maps each running Job instance to the ID of the job
+	instances map[Job]ID
+	events    *events
+	// coalesce is the window over which writes are batched into a single broadcast
+	coalesce time.Duration
+	// max if not zero, is the maximum number of jobs the runner holds before refusing new jobs
+	max int
+	// budget if not zero, is the maximum number of bytes of output retained across all jobs
+	budget      int
+	budgetMutex sync.Mutex
+	// usage is the running total of bytes retained by the stores of all jobs and groups, accessed atomically
+	usage int64
+	// newID returns the ID for each job run without a caller supplied ID
+	newID func() ID
+	// logger if not nil, is called with each complete line of output from every job
+	logger func(ID, []byte)
+	// overflowSize and overflow configure how output is buffered between
+	// reading it from the job and storing it, see WithOutputBuffer.
+	overflowSize int
+	overflow     OverflowPolicy
+	// sweepInterval is how often the sweeper removes jobs which have exceeded RetainAfterStop
+	sweepInterval time.Duration
+	sweepOnce     sync.Once
+	closeOnce     sync.Once
+	closed        chan struct{}
+	// slots holds a token for each running job when the number of concurrent jobs is limited
+	slots chan struct{}
+	clock Clock
+	// hits, misses and evictions are the counters reported by CacheStats, accessed atomically
+	hits      int64
+	misses    int64
+	evictions int64
+	// uses is incremented each time a job is added or looked up, accessed atomically
+	uses int64
+	// groups maps the name of each group to the output shared by the jobs in the group
+	groups     map[string]*group
+	groupMutex sync.Mutex
+}
+
+// Option configures the runner created by NewJobRunner
+type Option func(*runner)
+
+// WithOutputStore provides a function which returns a new OutputStore for each job
+// the runner runs. If not provided, the runner stores job output in a BytesBufferStore.
+func WithOutputStore(fn func() OutputStore) Option {
+	return func(r *runner) {
+		r.newStore = fn
+	}
+}
+
+// WithCompression configures the runner to compress the output of each job using a CompressedStore,
+// trading CPU for memory. Readers receive the decompressed output.
+func WithCompression() Option {
+	return func(r *runner) {
+		r.newStore = func() OutputStore {
+			return NewCompressedStore()
+		}
+	}
+}
+
+// WithBroadcastInterval batches the output written by a job over the provided window, waking
+// readers once per window instead of once per write. This reduces the work done by readers of
+// jobs which perform many small writes, at the cost of delaying delivery by up to the window.
+func WithBroadcastInterval(d time.Duration) Option {
+	return func(r *runner) {
+		r.coalesce = d
+	}
+}
+
+// WithInstanceDetection configures the runner to refuse to run a Job instance which is already running,
+// as running the same instance twice would share the state of the instance between both jobs. Run
+// returns the ID of the job already running the instance along with ErrAlreadyRunning. Only Jobs
+// which are comparable, such as pointers, can be detected.
+func WithInstanceDetection() Option {
+	return func(r *runner) {
+		r.instances = make(map[Job]ID)
+	}
+}
+
+// WithIDGenerator provides a function which returns the ID for each job run without a caller
+// supplied ID. If not provided, the runner generates a UUID for each job. Run returns ErrJobExists
+// if the generated ID is already used by another job.
+func WithIDGenerator(fn func() ID) Option {
+	return func(r *runner) {
+		r.newID = fn
+	}
+}
+
+// WithOutputLogger provides a function which is called with each complete line of output, including
+// the trailing newline, as each job produces it. A partial line is held back until the rest of the line
+// is written or the job stops. The function is called from the go routine collecting the job output,
+// and should not block.
+func WithOutputLogger(fn func(id ID, line []byte)) Option {
+	return func(r *runner) {
+		r.logger = fn
+	}
+}
+
+// WithSweepInterval sets how often the runner removes stopped jobs which have exceeded
+// RunOptions.RetainAfterStop. Defaults to DefaultSweepInterval.
+func WithSweepInterval(d time.Duration) Option {
+	return func(r *runner) {
+		r.sweepInterval = d
+	}
+}
+
+// WithMaxConcurrent limits the number of jobs which run at the same time. Jobs run while the limit is
+// reached are queued, Run returns the ID of a queued job immediately and the job reports Status.Pending
+// until it is started once a running job stops. Stopping a queued job removes it from the queue without
+// ever calling Start, and the job reports Status.Canceled. Panics if n is less than 1.
+func WithMaxConcurrent(n int) Option {
+	if n < 1 {
+		panic(fmt.Sprintf("WithMaxConcurrent: A limit of %d jobs is not allowed", n))
+	}
+	return func(r *runner) {
+		r.slots = make(chan struct{}, n)
+	}
+}
+
+// WithClock provides the clock the runner uses to read the time and wait, such as the times reported
+// by Status, RetainAfterStop and restart backoff. Defaults to the system clock.
+func WithClock(c Clock) Option {
+	return func(r *runner) {
+		r.clock = c
+	}
+}
+
+// WithOutputBuffer buffers up to size chunks of output read from each job before the output is stored.
+// When the buffer is full, the policy determines if the job is blocked from writing until there is room,
+// or output is discarded such that the job never blocks on writing output. Discarded output is never
+// stored or delivered to readers, the number of bytes discarded is reported by Status.Dropped and is
+// included in Status.BytesWritten. By default output is not buffered and the job is blocked until each
+// write is stored. Panics if size is less than 1 with a policy which discards output, as there is no
+// buffer to discard output from.
+func WithOutputBuffer(size int, policy OverflowPolicy) Option {
+	if size < 1 && policy != OverflowBlock {
+		panic(fmt.Sprintf("WithOutputBuffer: A size of %d is not allowed with a policy which discards output", size))
+	}
+	return func(r *runner) {
+		r.overflowSize = size
+		r.overflow = policy
+	}
+}
+
+func NewJobRunner(capacity int, opts ...Option) Runner {
+	r := &runner{
+		// The runner evicts jobs itself, such that job priority is respected
+		jobs:     collections.NewLRUCache(0),
+		capacity: capacity,
+		keys:     make(map[string]ID),
+		events:   newEvents(),
+		newStore: func() OutputStore {
+			return NewBytesBufferStore()
+		},
+		newID: func() ID {
+			return ID(uuid.New().String())
+		},
+		sweepInterval: DefaultSweepInterval,
+		clock:         realClock{},
+		closed:        make(chan struct{}),
+	}
+	r.jobs.OnEvicted = func(_ collections.Key, value interface{}) {
+		j := value.(*jobIO)
+		if j.group != nil {
+			r.leave(j)
+		}
+		j.mutex.Lock()
+		r.account(&j.counted, 0)
+		j.mutex.Unlock()
+		r.event(j, EventEvicted)
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// NewJobRunnerBounded returns a runner which holds at most max jobs, running or stopped. Unlike
+// NewJobRunner, jobs are never evicted, once max jobs exist Run returns ErrCapacityExceeded until
+// the caller frees a slot by calling Remove.
+func NewJobRunnerBounded(max int, opts ...Option) Runner {
+	r := NewJobRunner(max, opts...).(*runner)
+	r.max = max
+	return r
+}
+
+// NewJobRunnerMemBudget returns a runner which retains at most the provided number of bytes of output
+// across all jobs. When new output would exceed the budget, the runner evicts the oldest stopped jobs,
+// then discards the oldest output of the oldest running jobs. Discarding output of running jobs requires
+// the job's OutputStore implement Truncater, which the BytesBufferStore and RingBufferStore do.
+func NewJobRunnerMemBudget(bytes int, opts ...Option) Runner {
+	r := NewJobRunner(0, opts...).(*runner)
+	r.budget = bytes
+	return r
+}
+
+func (r *runner) Run(ctx context.Context, job Job) (ID, error) {
+	return r.RunWithOptions(ctx, job, RunOptions{})
+}
+
+func (r *runner) RunWithOptions(ctx context.Context, job Job, opts RunOptions) (ID, error) {
+	return r.run(ctx, r.newID(), job, opts)
+}
+
+func (r *runner) RunWithID(ctx context.Context, id ID, job Job) error {
+	_, err := r.run(ctx, id, job, RunOptions{})
+	return err
+}
+
+func (r *runner) RunCancelable(ctx context.Context, job Job) (ID, context.CancelFunc, error) {
+	id, err := r.Run(ctx, job)
+	if err != nil {
+		return "", nil, err
+	}
+	var once sync.Once
+	return id, func() {
+		once.Do(func() {
+			_ = r.Stop(context.Background(), id)
+		})
+	}, nil
+}
+
+func (r *runner) RunAfter(ctx context.Context, after ID, job Job) (ID, error) {
+	obj, ok := r.get(after)
+	if !ok {
+		return r.Run(ctx, job)
+	}
+	j := obj.(*jobIO)
+
+	select {
+	case <-j.done:
+		return r.Run(ctx, job)
+	default:
+	}
+	return r.RunWithOptions(ctx, job, RunOptions{Barrier: j.done})
+}
+
+// store returns a new OutputStore for a job with the provided retention
+func (r *runner) store(retention Retention) OutputStore {
+	switch {
+	case !retention.set:
+		return r.newStore()
+	case retention.bytes == 0:
+		return NewBytesBufferStore()
+	}
+	return NewRingBufferStore(retention.bytes)
+}
+
+func (r *runner) run(ctx context.Context, id ID, job Job, opts RunOptions) (ID, error) {
+	if job == nil {
+		return "", ErrNilJob
+	}
+	if opts.ValidateOnly {
+		// A validated job must never be confused with a job which is already running
+		opts.IdempotencyKey = ""
+	}
+	j, reader := r.newJob(ctx, id, job, opts, r.store(opts.Retention))
+	id, err := r.launch(ctx, j, reader, nil)
+	if !opts.ValidateOnly {
+		return id, err
+	}
+	return r.validated(ctx, j, err)
+}
+
+// validated stops and removes a job launched with RunOptions.ValidateOnly, returning
+// the error the job was launched with.
+func (r *runner) validated(ctx context.Context, j *jobIO, err error) (ID, error) {
+	// A job which failed to start is not held by the runner unless it panicked
+	if obj, ok := r.jobs.Peek(j.id); !ok || obj.(*jobIO) != j {
+		return "", err
+	}
+
+	if err := r.Stop(ctx, j.id); err != nil && !errors.Is(err, ErrJobNotRunning) {
+		return "", err
+	}
+	select {
+	case <-j.done:
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+	_ = r.Remove(j.id)
+	return j.id, err
+}
+
+// newJob returns a new job which stores output in the provided store, and the reader the monitor
+// go routine reads the job output from.
+func (r *runner) newJob(ctx context.Context, id ID, job Job, opts RunOptions, store OutputStore) (*jobIO, *io.PipeReader) {
+	reader, writer := io.Pipe()
+
+	// The job context carries the values of the provided context, but is only cancelled
+	// once the job is stopped, as the provided context only governs the startup of the job.
+	jobCtx, cancel := context.WithCancel(valueContext{Context: ctx})
+
+	j := &jobIO{
+		ctx:      jobCtx,
+		cancel:   cancel,
+		id:       id,
+		br:       syncutil.NewBroadcaster(),
+		mutex:    &sync.Mutex{},
+		started:  r.clock.Now(),
+		writer:   writer,
+		store:    store,
+		job:      job,
+		opts:     opts,
+		ready:    make(chan struct{}),
+		halted:   make(chan struct{}),
+		isReady:  make(chan struct{}),
+		done:     make(chan struct{}),
+		attempts: 1,
+		pending:  opts.Barrier != nil,
+		mirrors:  append([]io.Writer(nil), opts.Mirrors...),
+		clock:    r.clock,
+		hash:     sha256.New(),
+	}
+	if acceptsInput(job) {
+		j.inputReader, j.input = io.Pipe()
+	}
+	return j, reader
+}
+
+// launch adds the job to the runner and starts it. If prev is not nil, the job replaces the stopped
+// job prev, and should the job fail to start it remains in the runner as a stopped job.
+func (r *runner) launch(ctx context.Context, j *jobIO, reader *io.PipeReader, prev *jobIO) (ID, error) {
+	job, opts, writer, cancel := j.job, j.opts, j.writer, j.cancel
+	if opts.IdempotencyKey != "" {
+		if id, ok := r.reserveKey(opts.IdempotencyKey, j.id); !ok {
+			cancel()
+			return id, nil
+		}
+	}
+
+	if id, ok := r.reserveInstance(job, j.id); !ok {
+		cancel()
+		r.releaseKey(opts.IdempotencyKey, j.id)
+		return id, ErrAlreadyRunning
+	}
+
+	// Only run the sweeper once a job needs it
+	if opts.RetainAfterStop != 0 {
+		r.sweepOnce.Do(func() {
+			r.wg.Go(r.sweep)
+		})
+	}
+
+	// Queue the job if the maximum number of concurrent jobs are running
+	queued := opts.Barrier != nil
+	var acquired bool
+	if r.slots != nil {
+		select {
+		case r.slots <- struct{}{}:
+			acquired = true
+		default:
+			queued = true
+		}
+	}
+	j.slot = acquired
+	j.pending = queued
+	var joined bool
+	if opts.Group != "" {
+		joined = r.join(j, opts.Group)
+	}
+
+	if err := r.add(j, prev); err != nil {
+		cancel()
+		if j.group != nil {
+			r.groupStop(j)
+			if joined {
+				r.leave(j)
+			}
+		}
+		r.releaseSlot(j)
+		r.releaseKey(opts.IdempotencyKey, j.id)
+		r.releaseInstance(job, j.id)
+		return "", err
+	}
+
+	// Spawn a go routine to monitor job output, storing the output into the j.store
+	r.wg.Go(func() {
+		r.monitor(j, reader)
+	})
+
+	// timeout fires once the job has exceeded the StartTimeout
+	var timeout <-chan time.Time
+	if opts.StartTimeout != 0 && !queued {
+		timeout = r.clock.After(opts.StartTimeout)
+	}
+
+	if queued {
+		// Start the job once the caller releases the barrier and a slot is available
+		r.wg.Go(func() {
+			if opts.Barrier != nil {
+				select {
+				case <-opts.Barrier:
+				case <-j.halted:
+					return
+				}
+			}
+			var slot bool
+			if r.slots != nil && !acquired {
+				select {
+				case r.slots <- struct{}{}:
+					slot = true
+				case <-j.halted:
+					return
+				}
+			}
+			// The job may have been stopped while waiting
+			j.mutex.Lock()
+			if j.stopping {
+				j.mutex.Unlock()
+				if slot {
+					<-r.slots
+				}
+				return
+			}
+			j.pending = false
+			j.slot = acquired || slot
+			starting := make(chan struct{})
+			j.starting = starting
+			j.mutex.Unlock()
+			err := startJob(j, writer)
+			j.mutex.Lock()
+			j.starting = nil
+			j.mutex.Unlock()
+			close(starting)
+			if err != nil {
+				writer.CloseWithError(err)
+				return
+			}
+			r.announce(j)
+			if opts.ValidateOnly && opts.ReadyWhen == nil {
+				// A validated job is ready once Start returns
+				close(j.isReady)
+			}
+		})
+	} else if err := r.start(ctx, j, writer, timeout); err != nil {
+		// A job which panicked is retained as a failed job, such that the panic is reported by Status
+		if errors.Is(err, ErrJobPanicked) {
+			writer.CloseWithError(err)
+			select {
+			case <-j.done:
+			case <-ctx.Done():
+			}
+			return j.id, err
+		}
+		r.abort(j, prev)
+		return "", err
+	} else {
+		r.announce(j)
+	}
+
+	select {
+	case <-j.ready:
+	case <-ctx.Done():
+		_ = job.Stop(j.ctx)
+		r.abort(j, prev)
+		return "", ctx.Err()
+	}
+
+	// A queued job which is validated must start before validation completes
+	if opts.ReadyWhen != nil || (opts.ValidateOnly && queued) {
+		select {
+		case <-j.isReady:
+		case <-j.done:
+			return j.id, ErrNotReady
+		case <-ctx.Done():
+			_ = job.Stop(j.ctx)
+			r.abort(j, prev)
+			return "", ctx.Err()
+		case <-timeout:
+			_ = job.Stop(j.ctx)
+			r.abort(j, prev)
+			return "", ErrStartTimeout
+		}
+	}
+
+	return j.id, nil
+}
+
+// acceptsInput returns true if the job implements InputStarter and reads the input it is provided
+func acceptsInput(job Job) bool {
+	if _, ok := job.(InputStarter); !ok {
+		return false
+	}
+	if a, ok := job.(InputAccepter); ok {
+		return a.AcceptsInput()
+	}
+	return true
+}
+
+// startJob calls Start on the job, or StartWithInput if the job implements InputStarter, returning
+// an error which wraps ErrJobPanicked if the job panics
+func startJob(j *jobIO, writer io.Writer) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("%w: %v", ErrJobPanicked, p)
+		}
+	}()
+	if ij, ok := j.job.(InputStarter); ok && j.inputReader != nil {
+		return ij.StartWithInput(j.ctx, writer, j.inputReader)
+	}
+	return j.job.Start(j.ctx, writer)
+}
+
+// start calls Start on the job with the job context, cancelling the job
+// context if the provided context is cancelled before Start returns. If
+// timeout fires before Start returns, start returns ErrStartTimeout without
+// waiting for Start, and stops the job should Start eventually succeed.
+func (r *runner) start(ctx context.Context, j *jobIO, writer io.Writer, timeout <-chan time.Time) error {
+	var mutex sync.Mutex
+	var returned bool
+	started := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			mutex.Lock()
+			if !returned {
+				j.cancel()
+			}
+			mutex.Unlock()
+		case <-started:
+		}
+	}()
+
+	var err error
+	if timeout == nil {
+		err = startJob(j, writer)
+	} else {
+		result := make(chan error, 1)
+		go func() {
+			result <- startJob(j, writer)
+		}()
+		select {
+		case err = <-result:
+		case <-timeout:
+			err = ErrStartTimeout
+			j.cancel()
+			go func() {
+				if <-result == nil {
+					_ = j.job.Stop(j.ctx)
+				}
+			}()
+		}
+	}
+	mutex.Lock()
+	returned = true
+	mutex.Unlock()
+	close(started)
+	if err != nil {
+		return err
+	}
+
+	// If the job context was cancelled during startup, the job is of no use
+	if j.ctx.Err() != nil {
+		_ = j.job.Stop(j.ctx)
+		return ctx.Err()
+	}
+	return nil
+}
+
+func (r *runner) RunAll(ctx context.Context, jobs []Job) ([]ID, error) {
+	ids := make([]ID, 0, len(jobs))
+	for i, job := range jobs {
+		id, err := r.Run(ctx, job)
+		if err == nil {
+			ids = append(ids, id)
+			continue
+		}
+
+		// Stop all the jobs which have already started
+		errs := []error{fmt.Errorf("while starting job %d: %w", i, err)}
+		for _, started := range ids {
+			if err := r.Stop(ctx, started); err != nil && !errors.Is(err, ErrJobNotRunning) {
+				errs = append(errs, fmt.Errorf("while stopping '%s': %w", started, err))
+			}
+		}
+		return nil, errors.Join(errs...)
+	}
+	return ids, nil
+}
+
+func (r *runner) Restart(ctx context.Context, id ID, opts RestartOptions) error {
+	obj, ok := r.get(id)
+	if !ok {
+		return ErrJobNotFound
+	}
+	prev := obj.(*jobIO)
+	if atomic.LoadInt64(&prev.running) == 1 {
+		return ErrJobRunning
+	}
+
+	// The barrier was released when the job first started
+	runOpts := prev.opts
+	runOpts.Barrier = nil
+
+	prev.mutex.Lock()
+	// Output discarded once the previous run stopped unread is gone, there is nothing to preserve
+	preserve := opts.PreserveOutput && !prev.discarded
+	store := prev.store
+	if !preserve {
+		store = r.store(runOpts.Retention)
+	}
+	j, reader := r.newJob(ctx, id, prev.job, runOpts, store)
+	if preserve {
+		// The readers of the previous run may still be reading the store, the store is handed
+		// off along with its lock such that both jobs access the store under the same lock.
+		j.mutex = prev.mutex
+		prev.handedOff = true
+		j.read = prev.read
+		j.written = prev.written
+		j.offset = prev.offset
+		j.markers = prev.markers
+		j.hash = prev.hash
+		_, _ = j.store.Write([]byte(RestartSeparator))
+		j.offset += len(RestartSeparator)
+		_, _ = j.hash.Write([]byte(RestartSeparator))
+	}
+	prev.mutex.Unlock()
+
+	got, err := r.launch(ctx, j, reader, prev)
+	if err == nil && got != id {
+		// The idempotency key of the job was reserved by another job while it was stopped
+		return ErrJobExists
+	}
+	return err
+}
+
+// add the job to the cache, returns ErrJobExists if a job with the same ID already exists. If prev
+// is not nil the job replaces prev, returns ErrJobRunning if prev has already been replaced.
+func (r *runner) add(j *jobIO, prev *jobIO) error {
+	defer r.mutex.Unlock()
+	r.mutex.Lock()
+
+	if prev != nil {
+		// The job being replaced may have been removed or restarted by someone else
+		obj, ok := r.jobs.Peek(j.id)
+		if !ok {
+			return ErrJobNotFound
+		}
+		if obj.(*jobIO) != prev {
+			return ErrJobRunning
+		}
+		atomic.StoreInt64(&j.used, atomic.AddInt64(&r.uses, 1))
+		r.jobs.Add(j.id, j)
+		// The replaced job is not evicted, the retained output moves to the job which replaced it
+		prev.mutex.Lock()
+		r.account(&prev.counted, 0)
+		prev.mutex.Unlock()
+		j.mutex.Lock()
+		r.account(&j.counted, j.store.Len())
+		j.mutex.Unlock()
+		return nil
+	}
+
+	if _, ok := r.jobs.Peek(j.id); ok {
+		return ErrJobExists
+	}
+	if r.max != 0 && r.jobs.Size() >= r.max {
+		return ErrCapacityExceeded
+	}
+	if r.capacity != 0 && r.jobs.Size() >= r.capacity {
+		r.evict()
+	}
+	atomic.StoreInt64(&j.used, atomic.AddInt64(&r.uses, 1))
+	r.jobs.Add(j.id, j)
+	return nil
+}
+
+// sweep removes stopped jobs which have exceeded their RetainAfterStop
+// on each sweep interval until the runner is closed.
+func (r *runner) sweep() {
+	for {
+		select {
+		case <-r.clock.After(r.sweepInterval):
+		case <-r.closed:
+			return
+		}
+
+		for _, key := range r.jobs.Keys() {
+			obj, ok := r.jobs.Peek(key)
+			if !ok {
+				continue
+			}
+			j := obj.(*jobIO)
+			if j.opts.RetainAfterStop == 0 || atomic.LoadInt64(&j.running) == 1 {
+				continue
+			}
+			j.mutex.Lock()
+			stopped := j.stopped
+			j.mutex.Unlock()
+			if !stopped.IsZero() && r.clock.Now().Sub(stopped) >= j.opts.RetainAfterStop {
+				r.jobs.Remove(j.id)
+			}
+		}
+	}
+}
+
+// evict removes a job to make room for a new job. The job with the lowest priority is evicted,
+// preferring stopped jobs over running jobs of the same priority, then the least recently used job.
+func (r *runner) evict() {
+	var victim *jobIO
+	for _, key := range r.jobs.Keys() {
+		obj, ok := r.jobs.Peek(key)
+		if !ok {
+			continue
+		}
+		j := obj.(*jobIO)
+		if victim == nil || evictBefore(j, victim) {
+			victim = j
+		}
+	}
+	if victim != nil {
+		r.jobs.Remove(victim.id)
+		atomic.AddInt64(&r.evictions, 1)
+	}
+}
+
+// get returns the job with the provided ID, counting the lookup as a cache hit or miss
+func (r *runner) get(id ID) (interface{}, bool) {
+	obj, ok := r.jobs.Get(id)
+	if ok {
+		atomic.StoreInt64(&obj.(*jobIO).used, atomic.AddInt64(&r.uses, 1))
+		atomic.AddInt64(&r.hits, 1)
+	} else {
+		atomic.AddInt64(&r.misses, 1)
+	}
+	return obj, ok
+}
+
+// evictBefore returns true if job a should be evicted before job b
+func evictBefore(a, b *jobIO) bool {
+	if a.opts.Priority != b.opts.Priority {
+		return a.opts.Priority < b.opts.Priority
+	}
+	aRunning := atomic.LoadInt64(&a.running) == 1
+	bRunning := atomic.LoadInt64(&b.running) == 1
+	if aRunning != bRunning {
+		return !aRunning
+	}
+	return atomic.LoadInt64(&a.used) < atomic.LoadInt64(&b.used)
 }
 
-type runner struct {
-	jobs  *collections.LRUCache
-	wg    syncutil.WaitGroup
-	mutex sync.Mutex
+// monitor collects the output of the job until the job is no longer running, restarting
+// the job according to the restart policy if the job exits with an error.
+func (r *runner) monitor(j *jobIO, reader *io.PipeReader) {
+	atomic.StoreInt64(&j.running, 1)
+	close(j.ready)
+
+	for {
+		err := r.collectRecovered(j, reader)
+		if err == io.EOF {
+			break
+		}
+		if reader = r.restart(j); reader == nil {
+			// The job has failed and will not be restarted
+			j.mutex.Lock()
+			j.err = err
+			j.mutex.Unlock()
+			break
+		}
+	}
+
+	if err := r.flushLog(j); err != nil {
+		j.mutex.Lock()
+		if j.err == nil {
+			j.err = err
+		}
+		j.mutex.Unlock()
+	}
+
+	if j.group != nil {
+		r.groupStop(j)
+	}
+
+	// Record the reason before readers can observe the job is no longer running
+	j.mutex.Lock()
+	switch {
+	case j.timedOut:
+		j.reason = EndTimedOut
+	case j.stopping:
+		j.reason = EndStopped
+	case j.err != nil:
+		j.reason = EndError
+	default:
+		j.reason = EndCompleted
+	}
+	j.mutex.Unlock()
+
+	j.cancel()
+	r.releaseKey(j.opts.IdempotencyKey, j.id)
+	r.releaseInstance(j.job, j.id)
+	atomic.StoreInt64(&j.running, 0)
+	r.releaseSlot(j)
+	// Unblock any writers of input which will never be read
+	if j.inputReader != nil {
+		_ = j.inputReader.CloseWithError(ErrJobNotRunning)
+	}
+	j.mutex.Lock()
+	j.stopped = r.clock.Now()
+	// Free the output no reader attached to read while the job was running
+	if j.opts.FreeOnStopIfUnread && !j.read && !j.handedOff {
+		j.store = NewBytesBufferStore()
+		j.discarded = true
+		r.account(&j.counted, 0)
+	}
+	j.br.Broadcast()
+	j.mutex.Unlock()
+	close(j.done)
+	r.event(j, EventStopped)
+}
+
+// event sends an event for the job, deferring the event until the job has started
+func (r *runner) event(j *jobIO, t EventType) {
+	defer j.eventMutex.Unlock()
+	j.eventMutex.Lock()
+
+	if !j.announced {
+		// Consecutive output events are sent as one
+		if n := len(j.deferred); t != EventOutput || n == 0 || j.deferred[n-1] != EventOutput {
+			j.deferred = append(j.deferred, t)
+		}
+		return
+	}
+	r.events.Send(t, j.id)
+}
+
+// announce sends EventStarted once Start has returned without error, followed by the events of
+// the job which were deferred until the job started.
+func (r *runner) announce(j *jobIO) {
+	defer j.eventMutex.Unlock()
+	j.eventMutex.Lock()
+
+	j.announced = true
+	r.events.Send(EventStarted, j.id)
+	for _, t := range j.deferred {
+		r.events.Send(t, j.id)
+	}
+	j.deferred = nil
+}
+
+// flushLog calls the logger with any partial line held back from the logger, returning an error
+// which wraps ErrJobPanicked if the logger panics.
+func (r *runner) flushLog(j *jobIO) (err error) {
+	if r.logger == nil {
+		return nil
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("%w: %v", ErrJobPanicked, p)
+		}
+	}()
+	if partial := j.logLines.Flush(); len(partial) != 0 {
+		r.logger(j.id, partial)
+	}
+	return nil
+}
+
+// collectRecovered calls collect, returning an error which wraps ErrJobPanicked if collect panics,
+// which is most likely due to a callback provided via RunOptions.
+func (r *runner) collectRecovered(j *jobIO, reader *io.PipeReader) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("%w: %v", ErrJobPanicked, p)
+			// Unblock the job writing to the pipe which is no longer read
+			_ = reader.CloseWithError(err)
+		}
+	}()
+	return r.collect(j, reader)
+}
+
+// collect stores all output read from the provided reader into the j.store until the
+// writer is closed, returning io.EOF if the writer was closed without error, or the
+// error the writer was closed with.
+func (r *runner) collect(j *jobIO, reader *io.PipeReader) error {
+	ch := make(chan []byte, r.overflowSize)
+	var readErr error
+	// done is closed once collect returns, such that the read go routine never
+	// blocks forever sending output which collect will no longer receive.
+	done := make(chan struct{})
+	defer close(done)
+
+	// Spawn a separate go routine as the read could block forever
+	go func() {
+		buf := make([]byte, 2024)
+		for {
+			n, err := reader.Read(buf)
+			if err != nil {
+				readErr = err
+				close(ch)
+				return
+			}
+			out := make([]byte, n)
+			copy(out, buf[:n])
+			if !r.send(j, ch, out, done) {
+				return
+			}
+		}
+	}()
+
+	// When coalescing, flush is armed by the first write in a window
+	// and readers are woken once the window has elapsed.
+	var flush <-chan time.Time
+	broadcast := func() {
+		j.mutex.Lock()
+		j.br.Broadcast()
+		j.mutex.Unlock()
+		flush = nil
+	}
+
+	// store the output in j.store, written is the number of bytes written by the job
+	// which may differ from the length of the output after transformation.
+	store := func(written int, line []byte) {
+		if r.budget != 0 {
+			line = r.reserve(line)
+		}
+		j.mutex.Lock()
+		if written != 0 && j.firstOutput.IsZero() {
+			j.firstOutput = r.clock.Now()
+		}
+		j.written += written
+		// Discard output written while paused
+		skip := j.paused || len(line) == 0
+		if !skip {
+			_, _ = j.store.Write(line)
+			r.account(&j.counted, j.store.Len())
+			j.offset += len(line)
+			_, _ = j.hash.Write(line)
+			if r.coalesce == 0 {
+				j.br.Broadcast()
+			} else if flush == nil {
+				flush = r.clock.After(r.coalesce)
+			}
+		}
+		j.mutex.Unlock()
+		if !skip {
+			r.mirror(j, line)
+			if j.group != nil {
+				r.groupWrite(j, line)
+			}
+			r.event(j, EventOutput)
+		}
+	}
+
+	// process the output read from the job and store the result
+	process := func(written int, line []byte) {
+		if j.opts.Transform != nil {
+			line = j.opts.Transform(line)
+		}
+		r.probe(j, line)
+		if r.logger != nil {
+			for _, l := range j.logLines.Split(line) {
+				r.logger(j.id, l)
+			}
+		}
+		if j.opts.CollapseRepeats {
+			line = j.collapse.Write(line)
+		}
+		if j.opts.TimestampLines {
+			line = j.timestamps.Write(line, r.clock.Now())
+		}
+		store(written, line)
+	}
+
+	// idle fires once each idle window has elapsed, the window is only extended when it elapses
+	// rather than on every write, such that a chatty job does not create a timer for each write.
+	var idle <-chan time.Time
+	var lastWrite time.Time
+	if j.opts.IdleTimeout != 0 {
+		lastWrite = r.clock.Now()
+		idle = r.clock.After(j.opts.IdleTimeout)
+	}
+
+	// undecoded holds the bytes not yet consumed by the decoder
+	var undecoded []byte
+	// chunks is the number of chunks read when sampling
+	var chunks int
+	for {
+		select {
+		case line, ok := <-ch:
+			if !ok {
+				if len(undecoded) != 0 {
+					out, _ := j.opts.Decoder(undecoded, true)
+					process(0, out)
+				}
+				// Store any repeated line held back by the collapser
+				if j.opts.CollapseRepeats {
+					line := j.collapse.Flush()
+					if j.opts.TimestampLines {
+						line = j.timestamps.Write(line, r.clock.Now())
+					}
+					store(0, line)
+				}
+				if flush != nil {
+					broadcast()
+				}
+				return readErr
+			}
+			if idle != nil {
+				lastWrite = r.clock.Now()
+			}
+			written := len(line)
+			// Keep one in every 1/Sample chunks, the rest only count towards the bytes written
+			if j.opts.Sample > 0 && j.opts.Sample < 1 {
+				chunks++
+				if int(float64(chunks)*j.opts.Sample) == int(float64(chunks-1)*j.opts.Sample) {
+					store(written, nil)
+					continue
+				}
+			}
+			if j.opts.Decoder != nil {
+				if len(undecoded) != 0 {
+					line = append(undecoded, line...)
+				}
+				out, n := j.opts.Decoder(line, false)
+				undecoded = append([]byte(nil), line[n:]...)
+				line = out
+			}
+			process(written, line)
+		case <-flush:
+			broadcast()
+		case <-idle:
+			if remaining := j.opts.IdleTimeout - r.clock.Now().Sub(lastWrite); remaining > 0 {
+				idle = r.clock.After(remaining)
+				continue
+			}
+			idle = r.idle(j)
+		}
+	}
+}
+
+// idle stops the job which has exceeded the IdleTimeout, returning the next idle window if the job
+// is still waiting to start. The job is stopped from a separate go routine as the job may block while
+// stopping until the output it writes is collected.
+func (r *runner) idle(j *jobIO) <-chan time.Time {
+	j.mutex.Lock()
+	pending := j.pending
+	if !pending && !j.stopping {
+		j.timedOut = true
+	}
+	j.mutex.Unlock()
+	if pending {
+		return r.clock.After(j.opts.IdleTimeout)
+	}
+
+	r.wg.Go(func() {
+		_ = r.stop(context.Background(), j)
+	})
+	return nil
+}
+
+// mirror writes the stored output to the mirrors of the job, a mirror which returns an error
+// is removed such that a broken mirror never affects the job or the other mirrors.
+func (r *runner) mirror(j *jobIO, line []byte) {
+	active := j.mirrors[:0]
+	for _, w := range j.mirrors {
+		if _, err := w.Write(line); err != nil {
+			if j.opts.MirrorError != nil {
+				j.opts.MirrorError(w, err)
+			}
+			continue
+		}
+		active = append(active, w)
+	}
+	j.mirrors = active
+}
+
+// send the output to the collect loop according to the overflow policy of the runner, returns
+// false if the collect loop has returned and will no longer receive output.
+func (r *runner) send(j *jobIO, ch chan []byte, out []byte, done <-chan struct{}) bool {
+	switch r.overflow {
+	case OverflowDropNewest:
+		select {
+		case ch <- out:
+		case <-done:
+			return false
+		default:
+			atomic.AddInt64(&j.dropped, int64(len(out)))
+		}
+	case OverflowDropOldest:
+		for {
+			select {
+			case ch <- out:
+				return true
+			case <-done:
+				return false
+			default:
+			}
+			// Discard the oldest output to make room
+			select {
+			case old := <-ch:
+				atomic.AddInt64(&j.dropped, int64(len(old)))
+			default:
+			}
+		}
+	default:
+		select {
+		case ch <- out:
+		case <-done:
+			return false
+		}
+	}
+	return true
+}
+
+// reserve makes room within the memory budget for the provided output, evicting the oldest stopped jobs
+// first, then truncating the output of the oldest running jobs, then the output of groups. Returns the
+// output to store, which is only the most recent bytes of the output if the output alone exceeds the
+// budget. Jobs are only scanned once the running total of retained output would exceed the budget,
+// such that output which fits within the budget is stored without contending with other jobs.
+func (r *runner) reserve(line []byte) []byte {
+	if len(line) > r.budget {
+		line = line[len(line)-r.budget:]
+	}
+	r.makeRoom(len(line))
+	return line
+}
+
+// makeRoom frees retained output until n more bytes fit within the memory budget, or nothing
+// is left which can be freed. See reserve. Concurrent writers which each fit within the budget
+// may together exceed the budget until the next write which does not fit.
+func (r *runner) makeRoom(n int) {
+	if int(atomic.LoadInt64(&r.usage))+n <= r.budget {
+		return
+	}
+
+	defer r.budgetMutex.Unlock()
+	r.budgetMutex.Lock()
+
+	type candidate struct {
+		job      *jobIO
+		retained int
+		running  bool
+	}
+
+	for {
+		excess := int(atomic.LoadInt64(&r.usage)) + n - r.budget
+		if excess <= 0 {
+			return
+		}
+
+		var candidates []candidate
+		for _, key := range r.jobs.Keys() {
+			obj, ok := r.jobs.Peek(key)
+			if !ok {
+				continue
+			}
+			c := candidate{job: obj.(*jobIO)}
+			c.job.mutex.Lock()
+			c.retained = c.job.store.Len()
+			c.job.mutex.Unlock()
+			c.running = atomic.LoadInt64(&c.job.running) == 1
+			candidates = append(candidates, c)
+		}
+
+		// Prefer stopped jobs, oldest first
+		sort.Slice(candidates, func(a, b int) bool {
+			if candidates[a].running != candidates[b].running {
+				return !candidates[a].running
+			}
+			return candidates[a].job.started.Before(candidates[b].job.started)
+		})
+
+		freed := false
+		for _, c := range candidates {
+			if !c.running {
+				r.jobs.Remove(c.job.id)
+				atomic.AddInt64(&r.evictions, 1)
+				freed = true
+				break
+			}
+			if c.retained == 0 {
+				continue
+			}
+			c.job.mutex.Lock()
+			t, ok := c.job.store.(Truncater)
+			if ok {
+				if excess > c.retained {
+					excess = c.retained
+				}
+				t.Truncate(excess)
+				r.account(&c.job.counted, c.job.store.Len())
+			}
+			c.job.mutex.Unlock()
+			if ok {
+				freed = true
+				break
+			}
+		}
+		if !freed {
+			freed = r.truncateGroups(excess)
+		}
+
+		// Nothing left which can free output
+		if !freed {
+			return
+		}
+	}
+}
+
+// account records the number of bytes retained by a store in the running total of output retained
+// by the runner, where counted is the number of bytes previously recorded for the store. The caller
+// must hold the lock which guards counted.
+func (r *runner) account(counted *int, retained int) {
+	atomic.AddInt64(&r.usage, int64(retained-*counted))
+	*counted = retained
+}
+
+// probe calls the ReadyWhen predicate with each complete line of output
+// until the predicate returns true, at which point the job is ready.
+func (r *runner) probe(j *jobIO, chunk []byte) {
+	if j.opts.ReadyWhen == nil || j.probed {
+		return
+	}
+	for _, line := range j.probeLines.Split(chunk) {
+		if j.opts.ReadyWhen(line) {
+			j.probed = true
+			j.probeLines = lineBuffer{}
+			close(j.isReady)
+			return
+		}
+	}
+}
+
+// backoff returns the time waited before the provided restart attempt, doubling the Backoff with
+// each attempt until MaxBackoff is reached such that the backoff never overflows.
+func (p RestartPolicy) backoff(attempt int) time.Duration {
+	limit := p.MaxBackoff
+	if limit == 0 {
+		limit = DefaultMaxBackoff
+	}
+	d := p.Backoff
+	for i := 1; i < attempt && d < limit; i++ {
+		d *= 2
+	}
+	if d > limit {
+		return limit
+	}
+	return d
+}
+
+// restart waits for the backoff period then starts the job again under the same ID,
+// returning the reader for the new job output. Returns nil if the job should not be
+// restarted because the job was stopped or has exhausted its restart attempts.
+func (r *runner) restart(j *jobIO) *io.PipeReader {
+	policy := j.opts.Restart
+
+	j.mutex.Lock()
+	attempts := j.attempts
+	j.mutex.Unlock()
+
+	if attempts > policy.MaxAttempts {
+		return nil
+	}
+
+	// Backoff exponentially with each attempt
+	select {
+	case <-r.clock.After(policy.backoff(attempts)):
+	case <-j.halted:
+		return nil
+	}
+
+	reader, writer := io.Pipe()
+	j.mutex.Lock()
+	if j.stopping {
+		j.mutex.Unlock()
+		return nil
+	}
+	j.writer = writer
+	j.attempts++
+	j.mutex.Unlock()
+
+	// Start the job in a separate go routine, as the job may write to the
+	// writer before returning, which would block until we read from the reader.
+	r.wg.Go(func() {
+		if err := startJob(j, writer); err != nil {
+			writer.CloseWithError(err)
+		}
+	})
+	return reader
+}
+
+// abort tears down a job which failed to start. Closing the writer causes the monitor
+// go routine to exit, the job is then removed from the cache as if it never existed,
+// unless the job replaced prev, in which case it remains as a stopped job.
+func (r *runner) abort(j *jobIO, prev *jobIO) {
+	j.halt().Close()
+	j.cancel()
+	if prev == nil {
+		r.jobs.Remove(j.id)
+	}
+	r.releaseKey(j.opts.IdempotencyKey, j.id)
+	r.releaseInstance(j.job, j.id)
+}
+
+// releaseSlot releases the slot held by the job when the runner limits the number of concurrent jobs
+func (r *runner) releaseSlot(j *jobIO) {
+	j.mutex.Lock()
+	slot := j.slot
+	j.slot = false
+	j.mutex.Unlock()
+	if slot {
+		<-r.slots
+	}
+}
+
+// reserveKey reserves the idempotency key for the provided job id. If the key is
+// already reserved by a running job, returns the id of that job and false.
+func (r *runner) reserveKey(key string, id ID) (ID, bool) {
+	defer r.keyMutex.Unlock()
+	r.keyMutex.Lock()
+
+	if existing, ok := r.keys[key]; ok {
+		return existing, false
+	}
+	r.keys[key] = id
+	return id, true
+}
+
+// releaseKey releases the idempotency key if it is reserved by the provided job id
+func (r *runner) releaseKey(key string, id ID) {
+	if key == "" {
+		return
+	}
+	defer r.keyMutex.Unlock()
+	r.keyMutex.Lock()
+
+	if r.keys[key] == id {
+		delete(r.keys, key)
+	}
+}
+
+// reserveInstance reserves the job instance for the provided job id when instance detection
+// is enabled. If the instance is already running, returns the id of that job and false.
+// Jobs which are not comparable can not be tracked and are always reserved.
+func (r *runner) reserveInstance(job Job, id ID) (ID, bool) {
+	if r.instances == nil || !isComparable(job) {
+		return id, true
+	}
+	defer r.keyMutex.Unlock()
+	r.keyMutex.Lock()
+
+	if existing, ok := r.instances[job]; ok {
+		return existing, false
+	}
+	r.instances[job] = id
+	return id, true
+}
+
+// isComparable returns true if the job can be used as a map key. A comparable type may still hold
+// an uncomparable value, such as a struct with an interface field holding a slice, comparing such
+// a value panics.
+func isComparable(job Job) (ok bool) {
+	defer func() {
+		if recover() != nil {
+			ok = false
+		}
+	}()
+	return job == job
+}
+
+// releaseInstance releases the job instance if it is reserved by the provided job id
+func (r *runner) releaseInstance(job Job, id ID) {
+	if r.instances == nil || !isComparable(job) {
+		return
+	}
+	defer r.keyMutex.Unlock()
+	r.keyMutex.Lock()
+
+	if r.instances[job] == id {
+		delete(r.instances, job)
+	}
+}
+
+func (r *runner) NewReader(id ID) (io.ReadCloser, error) {
+	reader, _, err := r.NewReaderDone(id)
+	return reader, err
+}
+
+func (r *runner) NewReaderDone(id ID) (io.ReadCloser, <-chan struct{}, error) {
+	return r.newReader(id, readerOptions{})
+}
+
+func (r *runner) NewReaderTimeout(id ID, timeout time.Duration) (io.ReadCloser, error) {
+	reader, _, err := r.newReader(id, readerOptions{backlogTimeout: timeout})
+	return reader, err
+}
+
+func (r *runner) NewReaderLimited(id ID, bytesPerSec int) (io.ReadCloser, error) {
+	reader, _, err := r.newReader(id, readerOptions{bytesPerSec: bytesPerSec})
+	return reader, err
+}
+
+func (r *runner) NewResumableReader(id ID, offset int) (io.ReadCloser, func() int, error) {
+	delivered := new(int64)
+	reader, _, err := r.newReader(id, readerOptions{offset: offset, delivered: delivered})
+	if err != nil {
+		return nil, nil, err
+	}
+	return reader, func() int { return int(atomic.LoadInt64(delivered)) }, nil
+}
+
+// readerBufferSize is the size of the buffer readers use to copy from stores which implement copier
+const readerBufferSize = 32 * 1024
+
+// copier is implemented by an OutputStore which can copy the bytes written after an
+// offset into a buffer provided by the caller, such as the RingBufferStore.
+type copier interface {
+	CopyTo([]byte, int) (int, int)
+}
+
+// readerOptions are the options for readers created by newReader
+type readerOptions struct {
+	// backlogTimeout if not zero, is how long the reader has to accept the backlog
+	backlogTimeout time.Duration
+	// offset is the offset in the store the reader begins reading from
+	offset int
+	// delivered if not nil, is updated with the offset just past the last byte delivered to the reader
+	delivered *int64
+	// bytesPerSec if not zero, is the maximum rate at which bytes are delivered to the reader
+	bytesPerSec int
+	// name if not empty, is the ID the reader is registered under for CloseReader
+	name string
+	// reason if not nil, is set to a function which reports why the output of the job ended
+	reason *func() EndReason
+}
+
+// deliver records the offset just past the last byte delivered to the reader
+func (o readerOptions) deliver(offset int) {
+	if o.delivered != nil {
+		atomic.StoreInt64(o.delivered, int64(offset))
+	}
+}
+
+func (r *runner) newReader(id ID, opts readerOptions) (io.ReadCloser, <-chan struct{}, error) {
+	defer r.mutex.Unlock()
+	r.mutex.Lock()
+
+	// Close waits for the readers registered before the runner was closed
+	select {
+	case <-r.closed:
+		return nil, nil, ErrRunnerClosed
+	default:
+	}
+
+	obj, ok := r.get(id)
+	if !ok {
+		return nil, nil, ErrJobNotFound
+	}
+	j := obj.(*jobIO)
+	done := make(chan struct{})
+	if opts.reason != nil {
+		*opts.reason = j.endReason
+	}
+
+	name := opts.name
+	if name == "" {
+		name = uuid.New().String()
+	}
+
+	// Count the reader as attached until it is closed or has read all the output
+	j.mutex.Lock()
+	if j.discarded {
+		j.mutex.Unlock()
+		return nil, nil, ErrOutputDiscarded
+	}
+	j.read = true
+	unattach := j.attach(name)
+	j.mutex.Unlock()
+	var once sync.Once
+	detach := func() {
+		once.Do(func() {
+			unattach()
+			close(done)
+		})
+	}
+
+	// If the job isn't running, then copy the current buffer
+	// into a read closer and return that to the caller.
+	if atomic.LoadInt64(&j.running) == 0 {
+		j.mutex.Lock()
+		defer j.mutex.Unlock()
+		data, next := j.store.ReadOffset(opts.offset)
+		start := next - len(data)
+		opts.deliver(start)
+		reader := &doneReader{reader: bytes.NewReader(data), done: detach, read: func(n int) {
+			start += n
+			opts.deliver(start)
+		}}
+		j.handle(name, reader.disconnect)
+		return reader, done, nil
+	}
+
+	// Register with the broadcaster before reading from the store, such that
+	// we don't miss a broadcast sent between our first read and our first wait.
+	wait := j.br.WaitChan(name)
+
+	// Create a go routine that sends all unread bytes to the reader then
+	// waits for new bytes to be written to the j.store via the broadcaster.
+	var limit *limiter
+	if opts.bytesPerSec > 0 {
+		limit = newLimiter(opts.bytesPerSec, r.clock)
+	}
+
+	// Stores which can copy into a buffer are read without allocating on each read
+	var buf []byte
+	reader, writer := io.Pipe()
+	closed := make(chan struct{})
+	p := &pipeReader{PipeReader: reader, writer: writer, closed: closed}
+	j.mutex.Lock()
+	c, isCopier := j.store.(copier)
+	j.handle(name, p.disconnect)
+	j.mutex.Unlock()
+	if isCopier {
+		buf = make([]byte, readerBufferSize)
+	}
+
+	r.wg.Go(func() {
+		defer func() {
+			j.br.Remove(name)
+			detach()
+		}()
+
+		// The first writes deliver the backlog, close the reader with an error
+		// if the reader doesn't accept the backlog within the timeout.
+		var stopTimer func() bool
+		if opts.backlogTimeout != 0 {
+			stopTimer = afterFunc(r.clock, opts.backlogTimeout, func() {
+				writer.CloseWithError(ErrReaderTimeout)
+			})
+		}
+
+		var idx = opts.offset
+		first := true
+		for {
+			// Grab any bytes from the store we haven't sent to our reader. Check if the job
+			// is running while holding the mutex, such that we know no more bytes will be
+			// written to the store after this read if the job is no longer running.
+			var dst []byte
+			var next int
+			j.mutex.Lock()
+			if isCopier {
+				var n int
+				n, next = c.CopyTo(buf, idx)
+				dst = buf[:n]
+			} else {
+				dst, next = j.store.ReadOffset(idx)
+			}
+			running := atomic.LoadInt64(&j.running) == 1
+			j.mutex.Unlock()
+			// If the buffer was filled, there may be more bytes to read
+			more := isCopier && len(dst) == len(buf)
+
+			// Preform the Write() outside the mutex as it could block, and we don't
+			// want to hold on to the mutex lock for long
+			if first {
+				// Output discarded by the store was never delivered
+				opts.deliver(next - len(dst))
+			}
+			if len(dst) != 0 {
+				n, err := limit.write(writer, dst, closed)
+				opts.deliver(next - len(dst) + n)
+				if err != nil {
+					// If the reader called Close() on the pipe, or the timeout expired
+					return
+				}
+			}
+			idx = next
+			if more {
+				continue
+			}
+			if first && stopTimer != nil && !stopTimer() {
+				// The timeout expired as the write completed
+				return
+			}
+			first = false
+
+			// The job routine will broadcast when it stops the job and no
+			// more bytes are available to read.
+			if !running {
+				writer.Close()
+				return
+			}
+
+			// Wait for the broadcaster to tell us there are new bytes to read.
+			select {
+			case <-wait:
+			case <-closed:
+				return
+			}
+		}
+	})
+
+	return p, done, nil
+}
+
+// attach counts the reader with the provided name as attached to the job, returning a function
+// which detaches the reader. The caller must hold the job mutex.
+func (j *jobIO) attach(name string) func() {
+	if j.readers == 0 {
+		j.detached = make(chan struct{})
+	}
+	j.readers++
+	return func() {
+		j.mutex.Lock()
+		j.readers--
+		if j.readers == 0 {
+			close(j.detached)
+		}
+		delete(j.handles, name)
+		j.mutex.Unlock()
+	}
+}
+
+// handle registers the function which disconnects the named reader, the caller must hold j.mutex
+func (j *jobIO) handle(name string, disconnect func()) {
+	if j.handles == nil {
+		j.handles = make(map[string]func())
+	}
+	j.handles[name] = disconnect
+}
+
+func (r *runner) NewReaderHandle(id ID) (*ReaderHandle, error) {
+	name := uuid.New().String()
+	var reason func() EndReason
+	reader, _, err := r.newReader(id, readerOptions{name: name, reason: &reason})
+	if err != nil {
+		return nil, err
+	}
+	return &ReaderHandle{ReadCloser: reader, ID: name, reason: reason}, nil
+}
+
+func (r *runner) CloseReader(id ID, readerID string) error {
+	obj, ok := r.get(id)
+	if !ok {
+		return ErrJobNotFound
+	}
+	j := obj.(*jobIO)
+
+	j.mutex.Lock()
+	disconnect, ok := j.handles[readerID]
+	j.mutex.Unlock()
+	if !ok {
+		return ErrReaderNotFound
+	}
+	disconnect()
+	return nil
+}
+
+func (r *runner) Input(id ID) (io.WriteCloser, error) {
+	obj, ok := r.get(id)
+	if !ok {
+		return nil, ErrJobNotFound
+	}
+	j := obj.(*jobIO)
+
+	if j.input == nil {
+		return nil, ErrNoInput
+	}
+	if err := requireRunning(j); err != nil {
+		return nil, err
+	}
+	return j.input, nil
+}
+
+func (r *runner) Snapshot(id ID) ([]byte, int, error) {
+	obj, ok := r.get(id)
+	if !ok {
+		return nil, 0, ErrJobNotFound
+	}
+	j := obj.(*jobIO)
+
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	if j.discarded {
+		return nil, 0, ErrOutputDiscarded
+	}
+	data, offset := j.store.ReadOffset(0)
+	return data, offset, nil
+}
+
+func (r *runner) OutputHash(id ID) ([]byte, error) {
+	obj, ok := r.get(id)
+	if !ok {
+		return nil, ErrJobNotFound
+	}
+	j := obj.(*jobIO)
+
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	return j.hash.Sum(nil), nil
+}
+
+func (r *runner) Mark(id ID, name string) (int, error) {
+	obj, ok := r.get(id)
+	if !ok {
+		return 0, ErrJobNotFound
+	}
+	j := obj.(*jobIO)
+
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	if j.markers == nil {
+		j.markers = make(map[string]int)
+	}
+	j.markers[name] = j.offset
+	return j.offset, nil
+}
+
+func (r *runner) Markers(id ID) (map[string]int, error) {
+	obj, ok := r.get(id)
+	if !ok {
+		return nil, ErrJobNotFound
+	}
+	j := obj.(*jobIO)
+
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	markers := make(map[string]int, len(j.markers))
+	for name, offset := range j.markers {
+		markers[name] = offset
+	}
+	return markers, nil
 }
 
-func NewJobRunner(capacity int) Runner {
-	return &runner{
-		jobs: collections.NewLRUCache(capacity),
+func (r *runner) Search(id ID, re *regexp.Regexp, max int) ([]Match, error) {
+	data, end, err := r.Snapshot(id)
+	if err != nil {
+		return nil, err
 	}
-}
 
-func (r *runner) Run(ctx context.Context, job Job) (ID, error) {
-	reader, writer := io.Pipe()
+	var matches []Match
+	offset := end - len(data)
+	for len(data) != 0 && (max <= 0 || len(matches) < max) {
+		line := data
+		next := len(data)
+		if i := bytes.IndexByte(data, '\n'); i != -1 {
+			line = data[:i]
+			next = i + 1
+		}
+		if re.Match(line) {
+			matches = append(matches, Match{Offset: offset, Line: string(line)})
+		}
+		data = data[next:]
+		offset += next
+	}
+	return matches, nil
+}
 
-	j := jobIO{
-		id:      ID(uuid.New().String()),
-		br:      syncutil.NewBroadcaster(),
-		started: time.Now(),
-		writer:  writer,
-		job:     job,
+func (r *runner) BufferLen(id ID) (int, error) {
+	obj, ok := r.get(id)
+	if !ok {
+		return 0, ErrJobNotFound
 	}
+	j := obj.(*jobIO)
 
-	// Spawn a go routine to monitor job output, storing the output into the j.buffer
-	r.wg.Go(func() {
-		ch := make(chan []byte)
-		atomic.StoreInt64(&j.running, 1)
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	return j.store.Len(), nil
+}
 
-		// Spawn a separate go routine as the read could block forever
-		go func() {
-			buf := make([]byte, 2024)
-			for {
-				n, err := reader.Read(buf)
-				if err != nil {
-					close(ch)
-					return
-				}
-				out := make([]byte, n)
-				copy(out, buf[:n])
-				ch <- out
-			}
-		}()
+func (r *runner) CacheStats() (hits, misses, evictions int) {
+	return int(atomic.LoadInt64(&r.hits)), int(atomic.LoadInt64(&r.misses)), int(atomic.LoadInt64(&r.evictions))
+}
 
-		for {
-			select {
-			case line, ok := <-ch:
-				if !ok {
-					atomic.StoreInt64(&j.running, 0)
-					j.mutex.Lock()
-					j.stopped = time.Now()
-					j.br.Broadcast()
-					j.mutex.Unlock()
-					return
-				}
-				j.mutex.Lock()
-				j.buffer.Write(line)
-				j.br.Broadcast()
-				j.mutex.Unlock()
-			}
-		}
-	})
-	r.jobs.Add(j.id, &j)
+func (r *runner) TotalBufferedBytes() int {
+	defer r.mutex.Unlock()
+	r.mutex.Lock()
 
-	if err := job.Start(ctx, writer); err != nil {
-		return "", err
+	var total int
+	for _, key := range r.jobs.Keys() {
+		// Peek such that measuring the jobs does not change the order they are evicted
+		obj, ok := r.jobs.Peek(key)
+		if !ok {
+			continue
+		}
+		j := obj.(*jobIO)
+		j.mutex.Lock()
+		total += j.store.Len()
+		j.mutex.Unlock()
 	}
+	return total + r.groupBytes()
+}
 
-	for {
-		if atomic.LoadInt64(&j.running) == 1 {
-			break
-		}
-		select {
-		case <-ctx.Done():
-			return "", ctx.Err()
-		}
+func (r *runner) ReaderCount(id ID) (int, error) {
+	obj, ok := r.get(id)
+	if !ok {
+		return 0, ErrJobNotFound
 	}
+	j := obj.(*jobIO)
 
-	return j.id, nil
+	defer j.mutex.Unlock()
+	j.mutex.Lock()
+	return j.readers, nil
 }
 
-func (r *runner) NewReader(id ID) (io.ReadCloser, error) {
+func (r *runner) Stop(ctx context.Context, id ID) error {
 	defer r.mutex.Unlock()
 	r.mutex.Lock()
 
-	obj, ok := r.jobs.Get(id)
+	obj, ok := r.get(id)
 	if !ok {
-		return nil, ErrJobNotFound
+		return ErrJobNotFound
 	}
 	j := obj.(*jobIO)
 
-	// If the job isn't running, then copy the current buffer
-	// into a read closer and return that to the caller.
-	if atomic.LoadInt64(&j.running) == 0 {
-		j.mutex.Lock()
-		defer j.mutex.Unlock()
-		buf := bytes.Buffer{}
-		buf.Write(j.buffer.Bytes())
-		return io.NopCloser(&buf), nil
+	if err := requireRunning(j); err != nil {
+		return err
 	}
 
-	// Create a go routine that sends all unread bytes to the reader then
-	// waits for new bytes to be written to the j.buffer via the broadcaster.
-	reader, writer := io.Pipe()
+	return r.stop(ctx, j)
+}
+
+func (r *runner) TryStop(id ID) error {
+	if !r.mutex.TryLock() {
+		return ErrWouldBlock
+	}
+
+	obj, ok := r.get(id)
+	if !ok {
+		r.mutex.Unlock()
+		return ErrJobNotFound
+	}
+	j := obj.(*jobIO)
+
+	if err := requireRunning(j); err != nil {
+		r.mutex.Unlock()
+		return err
+	}
+	r.mutex.Unlock()
+
+	// Stop the job in the background, such that a job which is slow to stop
+	// finishes stopping after TryStop has returned ErrWouldBlock.
+	ctx, cancel := context.WithTimeout(context.Background(), tryStopWait)
+	result := make(chan error, 1)
 	r.wg.Go(func() {
-		var idx = 0
-		for {
-			// Grab any bytes from the buffer we haven't sent to our reader
-			j.mutex.Lock()
-			src := j.buffer.Bytes()
-			dst := make([]byte, j.buffer.Len()-idx)
-			copy(dst, src[idx:j.buffer.Len()])
-			j.mutex.Unlock()
+		defer cancel()
+		result <- r.stop(ctx, j)
+	})
 
-			// Preform the Write() outside the mutex as it could block, and we don't
-			// want to hold on to the mutex lock for long
-			n, err := writer.Write(dst)
-			if err != nil {
-				// If the reader called Close() on the pipe
-				return
-			}
-			idx += n
+	select {
+	case err := <-result:
+		if errors.Is(err, context.DeadlineExceeded) {
+			return ErrWouldBlock
+		}
+		return err
+	case <-ctx.Done():
+		return ErrWouldBlock
+	}
+}
 
-			// The job routine will broadcast when it stops the job and no
-			// more bytes are available to read.
-			if atomic.LoadInt64(&j.running) == 0 {
-				writer.Close()
-				return
-			}
+func (r *runner) StopGraceful(ctx context.Context, id ID, grace time.Duration) error {
+	r.mutex.Lock()
+	obj, ok := r.get(id)
+	if !ok {
+		r.mutex.Unlock()
+		return ErrJobNotFound
+	}
+	j := obj.(*jobIO)
 
-			// Wait for the broadcaster to tell us there are new bytes to read.
-			j.br.Wait(string(j.id))
+	if err := requireRunning(j); err != nil {
+		r.mutex.Unlock()
+		return err
+	}
+	r.mutex.Unlock()
+
+	// The runner is not held while waiting on the job, such that other jobs are
+	// not blocked for the grace period.
+	writer := j.halt()
 
+	graceCtx, cancel := context.WithTimeout(ctx, grace)
+	defer cancel()
+	j.awaitStart(graceCtx)
+
+	var err error
+	if !j.isCanceled() {
+		err = j.job.Stop(graceCtx)
+	}
+	if err != nil {
+		// If the grace period expired before the job stopped, kill the job
+		if k, ok := j.job.(Killer); ok && ctx.Err() == nil && graceCtx.Err() != nil {
+			err = nil
+			if kerr := k.Kill(); kerr != nil {
+				err = fmt.Errorf("while killing '%s': %w", j.id, kerr)
+			}
 		}
-	})
+	}
 
-	return reader, nil
+	// Close the writer even if the job failed to stop, such that
+	// the monitor go routine does not wait on the job forever.
+	writer.Close()
+	j.cancel()
+	return err
 }
 
-func (r *runner) Stop(ctx context.Context, id ID) error {
+func (r *runner) Flush(id ID) error {
+	obj, ok := r.get(id)
+	if !ok {
+		return ErrJobNotFound
+	}
+	j := obj.(*jobIO)
+
+	if err := requireRunning(j); err != nil {
+		return err
+	}
+
+	j.mutex.Lock()
+	j.br.Broadcast()
+	j.mutex.Unlock()
+	return nil
+}
+
+func (r *runner) Remove(id ID) error {
 	defer r.mutex.Unlock()
 	r.mutex.Lock()
 
-	obj, ok := r.jobs.Get(id)
+	obj, ok := r.jobs.Peek(id)
+	if !ok {
+		return ErrJobNotFound
+	}
+	if atomic.LoadInt64(&obj.(*jobIO).running) == 1 {
+		return ErrJobRunning
+	}
+	r.jobs.Remove(id)
+	return nil
+}
+
+func (r *runner) Events() (<-chan Event, func()) {
+	return r.events.Subscribe()
+}
+
+func (r *runner) Pause(id ID) error {
+	return r.setPaused(id, true)
+}
+
+func (r *runner) Resume(id ID) error {
+	return r.setPaused(id, false)
+}
+
+func (r *runner) setPaused(id ID, paused bool) error {
+	obj, ok := r.get(id)
 	if !ok {
 		return ErrJobNotFound
 	}
 	j := obj.(*jobIO)
 
-	// Ignore if already stopped
+	if err := requireRunning(j); err != nil {
+		return err
+	}
+
+	j.mutex.Lock()
+	j.paused = paused
+	j.mutex.Unlock()
+	return nil
+}
+
+// requireRunning returns ErrJobNotRunning if the job is not running. Every operation which
+// requires a running job uses requireRunning, such that they report a stopped job consistently.
+func requireRunning(j *jobIO) error {
 	if atomic.LoadInt64(&j.running) == 0 {
 		return ErrJobNotRunning
 	}
-
-	return r.stop(ctx, j)
+	return nil
 }
 
 func (r *runner) stop(ctx context.Context, j *jobIO) error {
-	// Stop the job
-	if err := j.job.Stop(ctx); err != nil {
-		return err
+	// Prevent the job from being restarted once stopped
+	writer := j.halt()
+	j.awaitStart(ctx)
+
+	// Stop the job, even if the job fails to stop we close the writer
+	// such that the monitor go routine does not wait on the job forever.
+	var err error
+	if !j.isCanceled() {
+		err = j.job.Stop(ctx)
 	}
 
 	// Close the writer, this should tell the reading go routine to shutdown
-	j.writer.Close()
-	return nil
+	writer.Close()
+	j.cancel()
+	return err
+}
+
+// awaitStart waits for Start to return if the job is starting, otherwise anything started by
+// Start would be left running after the job was stopped. The job context is cancelled such
+// that Start does not wait to start a job which is stopping.
+func (j *jobIO) awaitStart(ctx context.Context) {
+	j.mutex.Lock()
+	starting := j.starting
+	j.mutex.Unlock()
+	if starting == nil {
+		return
+	}
+	j.cancel()
+	select {
+	case <-starting:
+	case <-ctx.Done():
+	}
 }
 
 func (r *runner) Status(id ID) (Status, bool) {
-	value, ok := r.jobs.Get(id)
+	value, ok := r.get(id)
 	if !ok {
 		return Status{}, false
 	}
 	return toStatus(value.(*jobIO)), true
 }
 
+func (r *runner) StatusMany(ids []ID) map[ID]Status {
+	defer r.mutex.Unlock()
+	r.mutex.Lock()
+
+	result := make(map[ID]Status, len(ids))
+	for _, id := range ids {
+		if value, ok := r.get(id); ok {
+			result[id] = toStatus(value.(*jobIO))
+		}
+	}
+	return result
+}
+
+func (r *runner) StatusCtx(ctx context.Context, id ID) (Status, error) {
+	value, ok := r.get(id)
+	if !ok {
+		return Status{}, ErrJobNotFound
+	}
+	return toStatusCtx(ctx, value.(*jobIO))
+}
+
+func (r *runner) ListCtx(ctx context.Context) ([]Status, error) {
+	var result []Status
+	for _, key := range r.jobs.Keys() {
+		obj, ok := r.jobs.Peek(key)
+		if !ok {
+			continue
+		}
+		s, err := toStatusCtx(ctx, obj.(*jobIO))
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, s)
+	}
+	return result, nil
+}
+
+// lock acquires the mutex, returning the context error if the
+// context is cancelled before the mutex could be acquired.
+func lock(ctx context.Context, mutex *sync.Mutex) error {
+	if mutex.TryLock() {
+		return nil
+	}
+	// Lock in the background and hand the mutex over, or release it
+	// once acquired if the caller has given up waiting
+	acquired := make(chan struct{})
+	abandoned := make(chan struct{})
+	go func() {
+		mutex.Lock()
+		select {
+		case acquired <- struct{}{}:
+		case <-abandoned:
+			mutex.Unlock()
+		}
+	}()
+	select {
+	case <-acquired:
+		return nil
+	case <-ctx.Done():
+		close(abandoned)
+		return ctx.Err()
+	}
+}
+
 func (r *runner) List() []Status {
 	defer r.mutex.Unlock()
 	r.mutex.Lock()
@@ -219,12 +2256,37 @@ func (r *runner) List() []Status {
 	return result
 }
 
+func (r *runner) ListPage(offset, limit int) ([]Status, int) {
+	result := r.List()
+	sort.Slice(result, func(i, j int) bool {
+		if !result[i].Started.Equal(result[j].Started) {
+			return result[i].Started.Before(result[j].Started)
+		}
+		return result[i].ID < result[j].ID
+	})
+
+	total := len(result)
+	if offset < 0 {
+		offset = 0
+	}
+	if offset > total {
+		offset = total
+	}
+	result = result[offset:]
+	if limit > 0 && limit < len(result) {
+		result = result[:limit]
+	}
+	return result, total
+}
+
 func (r *runner) Close(ctx context.Context) error {
+	r.closeOnce.Do(func() { close(r.closed) })
+
 	defer r.mutex.Unlock()
 	r.mutex.Lock()
 
-	for _, s := range r.List() {
-		obj, ok := r.jobs.Get(s.ID)
+	for _, key := range r.jobs.Keys() {
+		obj, ok := r.jobs.Peek(key)
 		if !ok {
 			continue
 		}
@@ -237,16 +2299,182 @@ func (r *runner) Close(ctx context.Context) error {
 			return fmt.Errorf("while stopping '%s': %w", j.id, err)
 		}
 	}
+
+	// Wait for all readers to finish reading the output of the stopped jobs
+	for _, key := range r.jobs.Keys() {
+		obj, ok := r.jobs.Peek(key)
+		if !ok {
+			continue
+		}
+		if err := obj.(*jobIO).waitReaders(ctx); err != nil {
+			return fmt.Errorf("while waiting for readers of '%s': %w", key, err)
+		}
+	}
+	return nil
+}
+
+// waitReaders waits until no readers are attached to the job or the context is cancelled
+func (j *jobIO) waitReaders(ctx context.Context) error {
+	j.mutex.Lock()
+	if j.readers == 0 {
+		j.mutex.Unlock()
+		return nil
+	}
+	detached := j.detached
+	j.mutex.Unlock()
+
+	select {
+	case <-detached:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// halt marks the job as stopping such that it is not restarted, and
+// returns the writer currently in use by the job.
+func (j *jobIO) halt() *io.PipeWriter {
+	defer j.mutex.Unlock()
+	j.mutex.Lock()
+
+	if !j.stopping {
+		j.stopping = true
+		// A job stopped before it started is never started
+		j.canceled = j.pending
+		j.pending = false
+		close(j.halted)
+	}
+	return j.writer
+}
+
+// endReason returns why the output of the job ended, or EndNone if the job has not stopped
+func (j *jobIO) endReason() EndReason {
+	defer j.mutex.Unlock()
+	j.mutex.Lock()
+	return j.reason
+}
+
+// isCanceled returns true if the job was stopped before it started
+func (j *jobIO) isCanceled() bool {
+	defer j.mutex.Unlock()
+	j.mutex.Lock()
+	return j.canceled
+}
+
+// valueContext carries the values of the parent context, but
+// not the deadline or cancellation of the parent context.
+type valueContext struct {
+	context.Context
+}
+
+func (valueContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+func (valueContext) Done() <-chan struct{}       { return nil }
+func (valueContext) Err() error                  { return nil }
+
+// doneReader calls done once the reader has returned
+// io.EOF or has been closed by the caller.
+type doneReader struct {
+	reader io.Reader
+	done   func()
+	// read if not nil, is called with the number of bytes read
+	read func(int)
+	// disconnected is set to 1 once the reader is closed by CloseReader
+	disconnected int32
+}
+
+func (d *doneReader) Read(p []byte) (int, error) {
+	if atomic.LoadInt32(&d.disconnected) == 1 {
+		return 0, ErrReaderClosed
+	}
+	n, err := d.reader.Read(p)
+	if d.read != nil {
+		d.read(n)
+	}
+	if err == io.EOF {
+		d.done()
+	}
+	return n, err
+}
+
+func (d *doneReader) Close() error {
+	d.done()
 	return nil
 }
 
+// disconnect closes the reader on behalf of CloseReader, subsequent reads return ErrReaderClosed
+func (d *doneReader) disconnect() {
+	atomic.StoreInt32(&d.disconnected, 1)
+	d.done()
+}
+
+// pipeReader notifies the reader go routine when the caller closes
+// the reader, such that the go routine exits without waiting for
+// the job to write more output.
+type pipeReader struct {
+	*io.PipeReader
+	writer *io.PipeWriter
+	closed chan struct{}
+	once   sync.Once
+}
+
+func (p *pipeReader) Close() error {
+	p.once.Do(func() { close(p.closed) })
+	return p.PipeReader.Close()
+}
+
+// disconnect closes the reader on behalf of CloseReader, subsequent reads return ErrReaderClosed
+func (p *pipeReader) disconnect() {
+	p.writer.CloseWithError(ErrReaderClosed)
+	p.once.Do(func() { close(p.closed) })
+}
+
 func toStatus(j *jobIO) Status {
 	j.mutex.Lock()
 	defer j.mutex.Unlock()
-	return Status{
-		ID:      j.id,
-		Running: atomic.LoadInt64(&j.running) == 1,
-		Started: j.started,
-		Stopped: j.stopped,
+	return j.status()
+}
+
+// toStatusCtx is identical to toStatus but returns the context error if the
+// context is cancelled before the job mutex could be acquired.
+func toStatusCtx(ctx context.Context, j *jobIO) (Status, error) {
+	if err := lock(ctx, j.mutex); err != nil {
+		return Status{}, err
+	}
+	defer j.mutex.Unlock()
+	return j.status(), nil
+}
+
+// status returns the status of the job, the caller must hold the job mutex
+func (j *jobIO) status() Status {
+	s := Status{
+		ID:          j.id,
+		Running:     atomic.LoadInt64(&j.running) == 1 && !j.pending,
+		Started:     j.started,
+		Stopped:     j.stopped,
+		Attempts:    j.attempts,
+		Paused:      j.paused,
+		Binary:      j.opts.Binary,
+		Retained:    j.store.Len(),
+		FirstOutput: j.firstOutput,
+		Dropped:     int(atomic.LoadInt64(&j.dropped)),
+		Pending:     j.pending,
+		Canceled:    j.canceled,
+		now:         j.clock.Now(),
+	}
+	s.BytesWritten = j.written + s.Dropped
+	if j.err != nil {
+		s.Err = j.err.Error()
+	}
+
+	if ec, ok := j.job.(ExitCoder); ok && !s.Running {
+		if code, ok := ec.ExitCode(); ok {
+			s.ExitCode = &code
+		}
+	}
+
+	// Calculate throughput using the time the job has been running
+	if elapsed := s.Duration(); elapsed > 0 {
+		s.Throughput = float64(j.written) / elapsed.Seconds()
 	}
+	return s
 }
@@ -0,0 +1,234 @@
+package steve
+
+import (
+	"bytes"
+	"compress/flate"
+	"io"
+	"sort"
+)
+
+// OutputStore stores the output written by a job. The runner writes all job output
+// to the store and readers use ReadOffset to retrieve output they have not yet read.
+// Implementations do not need to be thread safe, the runner guards all access to
+// the store with a mutex. Output is opaque bytes which may not be valid UTF-8, stores
+// must return exactly the bytes written.
+type OutputStore interface {
+	// Write appends the provided bytes to the store
+	Write([]byte) (int, error)
+
+	// ReadOffset returns all the retained bytes written after the provided offset, and the
+	// offset of the end of the returned bytes, which should be provided to the next call
+	// to ReadOffset to continue reading where the previous read left off.
+	ReadOffset(int) ([]byte, int)
+
+	// Len returns the number of bytes currently retained by the store
+	Len() int
+}
+
+// Truncater is implemented by an OutputStore which can discard the oldest output it retains
+type Truncater interface {
+	// Truncate discards the oldest n retained bytes. Offsets of the remaining bytes are unchanged.
+	Truncate(n int)
+}
+
+// BytesBufferStore is an OutputStore backed by a bytes.Buffer which retains
+// all output written to it, unless truncated.
+type BytesBufferStore struct {
+	buffer bytes.Buffer
+	// discarded is the number of bytes discarded by Truncate
+	discarded int
+}
+
+func NewBytesBufferStore() *BytesBufferStore {
+	return &BytesBufferStore{}
+}
+
+func (s *BytesBufferStore) Write(b []byte) (int, error) {
+	return s.buffer.Write(b)
+}
+
+func (s *BytesBufferStore) ReadOffset(offset int) ([]byte, int) {
+	total := s.discarded + s.buffer.Len()
+	if offset >= total {
+		return []byte(""), total
+	}
+	if offset < s.discarded {
+		offset = s.discarded
+	}
+
+	data := make([]byte, total-offset)
+	copy(data, s.buffer.Bytes()[offset-s.discarded:])
+	return data, total
+}
+
+func (s *BytesBufferStore) Len() int {
+	return s.buffer.Len()
+}
+
+func (s *BytesBufferStore) Truncate(n int) {
+	if n > s.buffer.Len() {
+		n = s.buffer.Len()
+	}
+	if n <= 0 {
+		return
+	}
+	s.buffer.Next(n)
+	s.discarded += n
+}
+
+// RingBufferStore is an OutputStore backed by a RingBuffer which retains
+// only the most recent output up to the capacity of the ring.
+type RingBufferStore struct {
+	ring *RingBuffer
+}
+
+func NewRingBufferStore(capacity int) *RingBufferStore {
+	return &RingBufferStore{
+		ring: NewRingBuffer(capacity),
+	}
+}
+
+func (s *RingBufferStore) Write(b []byte) (int, error) {
+	s.ring.Write(b)
+	return len(b), nil
+}
+
+func (s *RingBufferStore) ReadOffset(offset int) ([]byte, int) {
+	return s.ring.ReadOffset(offset)
+}
+
+func (s *RingBufferStore) Len() int {
+	return s.ring.Len()
+}
+
+// CopyTo copies the bytes written after the provided offset into dst without allocating, see RingBuffer.CopyTo
+func (s *RingBufferStore) CopyTo(dst []byte, offset int) (int, int) {
+	return s.ring.CopyTo(dst, offset)
+}
+
+func (s *RingBufferStore) Truncate(n int) {
+	s.ring.Truncate(n)
+}
+
+// CompressedBlockSize is the number of uncompressed bytes the CompressedStore
+// accumulates before compressing them into a block.
+const CompressedBlockSize = 32 * 1024
+
+// CompressedStore is an OutputStore which retains all output written to it like the
+// BytesBufferStore, but compresses the output in blocks of CompressedBlockSize to trade
+// CPU for memory. An index of the logical offset where each block begins allows
+// ReadOffset to decompress only the blocks at or after the requested offset.
+type CompressedStore struct {
+	blocks  []compressedBlock
+	pending bytes.Buffer
+	writer  *flate.Writer
+	total   int
+	size    int
+}
+
+type compressedBlock struct {
+	// offset is the logical offset of the first byte in the block
+	offset int
+	// length is the uncompressed length of the block
+	length int
+	data   []byte
+}
+
+func NewCompressedStore() *CompressedStore {
+	// NewWriter only returns an error for an invalid compression level
+	w, _ := flate.NewWriter(nil, flate.DefaultCompression)
+	return &CompressedStore{writer: w}
+}
+
+func (s *CompressedStore) Write(b []byte) (int, error) {
+	n := len(b)
+	for len(b) != 0 {
+		// Fill the pending block, compressing it once full
+		room := CompressedBlockSize - s.pending.Len()
+		if room > len(b) {
+			room = len(b)
+		}
+		s.pending.Write(b[:room])
+		b = b[room:]
+
+		if s.pending.Len() == CompressedBlockSize {
+			if err := s.compress(); err != nil {
+				return n - len(b), err
+			}
+		}
+	}
+	s.total += n
+	return n, nil
+}
+
+// compress the pending bytes into a new block
+func (s *CompressedStore) compress() error {
+	var buf bytes.Buffer
+	s.writer.Reset(&buf)
+	if _, err := s.writer.Write(s.pending.Bytes()); err != nil {
+		return err
+	}
+	if err := s.writer.Close(); err != nil {
+		return err
+	}
+
+	var offset int
+	if len(s.blocks) != 0 {
+		last := s.blocks[len(s.blocks)-1]
+		offset = last.offset + last.length
+	}
+	s.blocks = append(s.blocks, compressedBlock{
+		offset: offset,
+		length: s.pending.Len(),
+		data:   buf.Bytes(),
+	})
+	s.size += buf.Len()
+	s.pending.Reset()
+	return nil
+}
+
+func (s *CompressedStore) ReadOffset(offset int) ([]byte, int) {
+	if offset >= s.total {
+		return []byte(""), s.total
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	data := make([]byte, 0, s.total-offset)
+
+	// Find the first block which contains the offset
+	i := sort.Search(len(s.blocks), func(i int) bool {
+		return s.blocks[i].offset+s.blocks[i].length > offset
+	})
+	for ; i < len(s.blocks); i++ {
+		block := s.blocks[i]
+		buf := make([]byte, block.length)
+		// The block was compressed by us, it can only fail to decompress if memory is corrupt
+		if _, err := io.ReadFull(flate.NewReader(bytes.NewReader(block.data)), buf); err != nil {
+			panic("CompressedStore: failed to decompress block: " + err.Error())
+		}
+		if offset > block.offset {
+			buf = buf[offset-block.offset:]
+		}
+		data = append(data, buf...)
+	}
+
+	// Append the pending bytes which are not yet compressed
+	pending := s.pending.Bytes()
+	if start := s.total - len(pending); offset > start {
+		pending = pending[offset-start:]
+	}
+	data = append(data, pending...)
+	return data, s.total
+}
+
+func (s *CompressedStore) Len() int {
+	return s.total
+}
+
+// Size returns the number of bytes of memory used to store the output, which
+// is the size of all compressed blocks plus the bytes not yet compressed.
+func (s *CompressedStore) Size() int {
+	return s.size + s.pending.Len()
+}
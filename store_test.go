@@ -0,0 +1,237 @@
+package steve_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/mailgun/holster/v4/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thrawn01/steve"
+)
+
+func TestOutputStores(t *testing.T) {
+	stores := map[string]func() steve.OutputStore{
+		"BytesBufferStore": func() steve.OutputStore {
+			return steve.NewBytesBufferStore()
+		},
+		"RingBufferStore": func() steve.OutputStore {
+			return steve.NewRingBufferStore(steve.AllocSize * 10)
+		},
+		"CompressedStore": func() steve.OutputStore {
+			return steve.NewCompressedStore()
+		},
+	}
+
+	results := make(map[string]string)
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			runner := steve.NewJobRunner(20, steve.WithOutputStore(store))
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+			defer cancel()
+
+			id, err := runner.Run(ctx, &linesJob{count: 100})
+			require.NoError(t, err)
+
+			err = runner.Stop(ctx, id)
+			require.NoError(t, err)
+
+			testutil.UntilPass(t, 10, time.Millisecond*100, func(t testutil.TestingT) {
+				s, ok := runner.Status(id)
+				require.True(t, ok)
+				assert.False(t, s.Running)
+			})
+
+			r, err := runner.NewReader(id)
+			require.NoError(t, err)
+			out, err := io.ReadAll(r)
+			require.NoError(t, err)
+			require.NoError(t, r.Close())
+			results[name] = string(out)
+		})
+	}
+
+	assert.Contains(t, results["BytesBufferStore"], "line: 0\n")
+	assert.Contains(t, results["BytesBufferStore"], "line: 99\n")
+	assert.Equal(t, results["BytesBufferStore"], results["RingBufferStore"])
+	assert.Equal(t, results["BytesBufferStore"], results["CompressedStore"])
+}
+
+func TestStoreReadOffset(t *testing.T) {
+	for _, s := range []steve.OutputStore{
+		steve.NewBytesBufferStore(),
+		steve.NewRingBufferStore(100),
+		steve.NewCompressedStore(),
+	} {
+		_, err := s.Write([]byte("Hello"))
+		require.NoError(t, err)
+
+		data, offset := s.ReadOffset(0)
+		assert.Equal(t, "Hello", string(data))
+		assert.Equal(t, 5, offset)
+
+		_, err = s.Write([]byte(", World"))
+		require.NoError(t, err)
+
+		data, offset = s.ReadOffset(offset)
+		assert.Equal(t, ", World", string(data))
+		assert.Equal(t, 12, offset)
+		assert.Equal(t, 12, s.Len())
+
+		data, offset = s.ReadOffset(offset)
+		assert.Equal(t, "", string(data))
+		assert.Equal(t, 12, offset)
+	}
+}
+
+func TestCompressedStore(t *testing.T) {
+	s := steve.NewCompressedStore()
+	expected := steve.NewBytesBufferStore()
+
+	// Write repetitive output spanning several compressed blocks, with
+	// writes which do not align with the block boundaries.
+	for i := 0; i < 10_000; i++ {
+		line := []byte(fmt.Sprintf("line: %d the quick brown fox jumps over the lazy dog\n", i%10))
+		_, err := s.Write(line)
+		require.NoError(t, err)
+		_, _ = expected.Write(line)
+	}
+	assert.Equal(t, expected.Len(), s.Len())
+
+	// Round trip should be byte identical from any offset
+	for _, offset := range []int{0, 1, steve.CompressedBlockSize - 1, steve.CompressedBlockSize,
+		steve.CompressedBlockSize*3 + 7, s.Len() - 1, s.Len()} {
+		data, next := s.ReadOffset(offset)
+		want, wantNext := expected.ReadOffset(offset)
+		assert.Equal(t, want, data, "offset %d", offset)
+		assert.Equal(t, wantNext, next)
+	}
+
+	// Repetitive output should use much less memory than it would uncompressed
+	assert.Less(t, s.Size(), s.Len()/10)
+}
+
+func TestWithCompression(t *testing.T) {
+	runner := steve.NewJobRunner(20, steve.WithCompression())
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	id, err := runner.Run(ctx, &linesJob{count: 10_000})
+	require.NoError(t, err)
+
+	r, err := runner.NewReader(id)
+	require.NoError(t, err)
+	require.NoError(t, runner.Stop(ctx, id))
+
+	out, err := io.ReadAll(r)
+	require.NoError(t, err)
+
+	var expected bytes.Buffer
+	for i := 0; i < 10_000; i++ {
+		_, _ = fmt.Fprintf(&expected, "line: %d\n", i)
+	}
+	assert.Equal(t, expected.String(), string(out))
+}
+
+func TestStoreTruncate(t *testing.T) {
+	for _, s := range []steve.OutputStore{
+		steve.NewBytesBufferStore(),
+		steve.NewRingBufferStore(100),
+	} {
+		_, err := s.Write([]byte("Hello, World"))
+		require.NoError(t, err)
+
+		s.(steve.Truncater).Truncate(7)
+		assert.Equal(t, 5, s.Len())
+
+		// Offsets of the remaining bytes are unchanged
+		data, offset := s.ReadOffset(0)
+		assert.Equal(t, "World", string(data))
+		assert.Equal(t, 12, offset)
+		data, offset = s.ReadOffset(10)
+		assert.Equal(t, "ld", string(data))
+		assert.Equal(t, 12, offset)
+	}
+}
+
+func TestRingBufferStoreLargeBacklog(t *testing.T) {
+	runner := steve.NewJobRunner(20, steve.WithOutputStore(func() steve.OutputStore {
+		return steve.NewRingBufferStore(200_000)
+	}))
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	// A backlog larger than the buffer used by readers to copy from the ring
+	id, err := runner.Run(ctx, &linesJob{count: 10_000})
+	require.NoError(t, err)
+
+	r, err := runner.NewReader(id)
+	require.NoError(t, err)
+	require.NoError(t, runner.Stop(ctx, id))
+
+	out, err := io.ReadAll(r)
+	require.NoError(t, err)
+
+	var expected bytes.Buffer
+	for i := 0; i < 10_000; i++ {
+		_, _ = fmt.Fprintf(&expected, "line: %d\n", i)
+	}
+	assert.Equal(t, expected.String(), string(out))
+}
+
+func TestRetention(t *testing.T) {
+	runner := steve.NewJobRunner(20, steve.WithOutputStore(func() steve.OutputStore {
+		return steve.NewRingBufferStore(100)
+	}))
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	var expected bytes.Buffer
+	for i := 0; i < 1_000; i++ {
+		_, _ = fmt.Fprintf(&expected, "line: %d\n", i)
+	}
+
+	for _, test := range []struct {
+		name      string
+		retention steve.Retention
+		expected  string
+	}{
+		{
+			name:      "RetainAll",
+			retention: steve.RetainAll,
+			expected:  expected.String(),
+		},
+		{
+			name:      "RetainBytes",
+			retention: steve.RetainBytes(20),
+			expected:  "line: 998\nline: 999\n",
+		},
+		{
+			name:     "Default",
+			expected: expected.String()[expected.Len()-100:],
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			id, err := runner.RunWithOptions(ctx, &linesJob{count: 1_000}, steve.RunOptions{Retention: test.retention})
+			require.NoError(t, err)
+			require.NoError(t, runner.Stop(ctx, id))
+
+			testutil.UntilPass(t, 10, time.Millisecond*100, func(t testutil.TestingT) {
+				s, ok := runner.Status(id)
+				assert.True(t, ok)
+				assert.False(t, s.Running)
+			})
+
+			data, offset, err := runner.Snapshot(id)
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, string(data))
+			assert.Equal(t, expected.Len(), offset)
+		})
+	}
+
+	assert.Panics(t, func() { steve.RetainBytes(0) })
+}